@@ -1,24 +1,85 @@
 package bencoding
 
 import (
+	"bufio"
 	"bytes"
+	"encoding"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 )
 
 // Unmarshaller implements custom unmarshalling for bencoded entities.
-//
-// BUG: this is not recognized in Unmarshal()
+// UnmarshalBencoding is given the complete encoding of one value and must
+// copy it if the data is retained.
 type Unmarshaller interface {
 	UnmarshalBencoding([]byte) error
 }
 
+var unmarshallerType = reflect.TypeOf((*Unmarshaller)(nil)).Elem()
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// unmarshaller returns the Unmarshaller that val points to, allocating nil
+// pointers as necessary.  If val is addressable its address is considered.
+func unmarshaller(val reflect.Value) (Unmarshaller, bool) {
+	if val.Kind() != reflect.Ptr && val.CanAddr() {
+		val = val.Addr()
+	}
+	n := 0
+	typ := val.Type()
+	for typ.Kind() == reflect.Ptr && !typ.Implements(unmarshallerType) {
+		typ = typ.Elem()
+		n++
+	}
+	if typ.Kind() != reflect.Ptr {
+		return nil, false
+	}
+	for i := 0; i <= n; i++ {
+		if val.IsNil() {
+			if !val.CanSet() {
+				return nil, false
+			}
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		if i < n {
+			val = val.Elem()
+		}
+	}
+	return val.Interface().(Unmarshaller), true
+}
+
+// fieldCache holds the fields of previously seen struct types.
+var fieldCache struct {
+	sync.RWMutex
+	m map[reflect.Type]fields
+}
+
+// structFields returns the bencoded fields of a struct type sorted by name.
+// The result is cached and must not be modified.
 func structFields(typ reflect.Type) fields {
 	typ = derefType(typ)
+	fieldCache.RLock()
+	fs, ok := fieldCache.m[typ]
+	fieldCache.RUnlock()
+	if ok {
+		return fs
+	}
+	fs = typeFields(typ)
+	fieldCache.Lock()
+	if fieldCache.m == nil {
+		fieldCache.m = make(map[reflect.Type]fields)
+	}
+	fieldCache.m[typ] = fs
+	fieldCache.Unlock()
+	return fs
+}
+
+func typeFields(typ reflect.Type) fields {
 	if typ.Kind() != reflect.Struct {
 		panic("not a struct")
 	}
@@ -66,16 +127,161 @@ func Unmarshal(p []byte, dst interface{}) error {
 
 // A Decoder reads and decodes bencoded objects from an input stream.
 // It returns objects that are either an "Integer", "String", "List" or "Dict".
-//
-// BUG: Decoder's cannot be constructed for arbitrary io.Reader implementations.
 type Decoder struct {
 	stream []byte
 	pos    int
+
+	// r is the input of a decoder created by NewDecoder.  Each document
+	// is read from r into stream before it is decoded.
+	r *bufio.Reader
+
+	// arena mode state.  The strings and byte slices of the current
+	// document are slices of a copy of the document starting at stream
+	// offset base.
+	arena  bool
+	base   int
+	abytes []byte
+	astr   string
+
+	presize   bool
+	zerocopy  bool
+	usenumber bool
+
+	start int // offset of the current document
+	hooks map[string]func(raw []byte)
 }
 
 // NewDecoderBytes creates a new decoder from b.
 func NewDecoderBytes(b []byte) *Decoder {
-	return &Decoder{b, 0}
+	return &Decoder{stream: b}
+}
+
+// NewDecoder creates a new decoder reading from r.  Each document is read
+// from r as it is decoded, so documents can be decoded from a network
+// connection as they arrive.  The decoder buffers its input and may read
+// data from r beyond the documents decoded.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Buffered returns a reader of the data buffered by a decoder created by
+// NewDecoder but not yet decoded.  The reader is valid until the next call
+// to Decode.
+func (dec *Decoder) Buffered() io.Reader {
+	if dec.r == nil {
+		return bytes.NewReader(dec.stream[dec.pos:])
+	}
+	n := dec.r.Buffered()
+	p, _ := dec.r.Peek(n)
+	return bytes.NewReader(p)
+}
+
+// more prepares the next document for decoding, reading it from the
+// decoder's reader if it has one.  EOF is returned if the input is
+// consumed.
+func (dec *Decoder) more() error {
+	if dec.r == nil {
+		if dec.pos >= len(dec.stream) {
+			return EOF
+		}
+		return nil
+	}
+	buf := dec.stream[:0]
+	if dec.zerocopy {
+		// decoded values reference the previous document.
+		buf = nil
+	}
+	p, err := readDocument(dec.r, buf)
+	if err != nil {
+		return err
+	}
+	dec.stream = p
+	dec.pos = 0
+	return nil
+}
+
+// Reset discards dec's input and makes it decode p.  Options such as
+// UseArena and hooks are kept, so one Decoder can be reused for many
+// messages.
+func (dec *Decoder) Reset(p []byte) {
+	dec.r = nil
+	dec.stream = p
+	dec.pos = 0
+	dec.start = 0
+	// decoded values may still reference the previous arena.
+	dec.base = 0
+	dec.abytes = nil
+	dec.astr = ""
+}
+
+// ResetReader discards dec's input and makes it decode documents read from
+// r, as if returned by NewDecoder.  Options are kept, as with Reset, and the
+// read buffer of a streaming decoder is reused.
+func (dec *Decoder) ResetReader(r io.Reader) {
+	var stream []byte
+	if dec.r != nil && !dec.zerocopy {
+		// the previous document belongs to dec, not the caller.
+		stream = dec.stream[:0]
+	}
+	br := dec.r
+	dec.Reset(stream)
+	if br == nil {
+		br = bufio.NewReader(r)
+	} else {
+		br.Reset(r)
+	}
+	dec.r = br
+}
+
+// UseArena causes dec to allocate all strings and byte slices decoded from a
+// document out of a single copy of the document.  This reduces allocations
+// when decoding many small documents.  Any decoded string or byte slice
+// keeps the memory of its entire document alive, and byte slices decoded
+// from one document must not be appended to.
+func (dec *Decoder) UseArena() {
+	dec.arena = true
+}
+
+// UseZeroCopy causes dec to decode byte slices as slices of its input
+// rather than copies.  The input must not be modified or released while the
+// decoded values are in use.  Strings are still copied.
+func (dec *Decoder) UseZeroCopy() {
+	dec.zerocopy = true
+}
+
+// UseNumber causes dec to decode integers into interface{} values as
+// Number instead of int64, so integers of any magnitude can be decoded.
+func (dec *Decoder) UseNumber() {
+	dec.usenumber = true
+}
+
+// PresizeSlices causes dec to count the elements of each list decoded into a
+// typed slice and allocate the slice once, instead of growing it while
+// decoding.  Counting costs an extra scan of the list, which pays off for
+// long lists such as peer and file lists.
+func (dec *Decoder) PresizeSlices() {
+	dec.presize = true
+}
+
+// Hook causes fn to be called with the raw encoding of the value of key
+// whenever key is decoded from a top-level dictionary.  Raw is a slice of the
+// decoder's input and is only valid during the call.  Hooks allow, for
+// example, hashing a sub-value while it is decoded.  Top-level dictionaries
+// decoded by an Unmarshaller do not trigger hooks.
+func (dec *Decoder) Hook(key string, fn func(raw []byte)) {
+	if dec.hooks == nil {
+		dec.hooks = make(map[string]func([]byte))
+	}
+	dec.hooks[key] = fn
+}
+
+// hook returns the hook for key in a dictionary, or nil.  Top is true if the
+// dictionary is the top-level value of the document.
+func (dec *Decoder) hook(top bool, key []byte) func([]byte) {
+	if !top || dec.hooks == nil {
+		return nil
+	}
+	return dec.hooks[string(key)]
 }
 
 // Decode reads one object from the input stream
@@ -84,10 +290,35 @@ func (dec *Decoder) Decode(dst interface{}) error {
 	if val.Kind() != reflect.Ptr {
 		return fmt.Errorf("destination is not a pointer")
 	}
-	if !val.IsNil() {
-		return dec.nextObject(reflect.Indirect(val))
+	if val.IsNil() {
+		return fmt.Errorf("nil destination")
+	}
+	err := dec.more()
+	if err != nil {
+		return err
+	}
+	if dec.arena {
+		err := dec.loadArena()
+		if err != nil {
+			return err
+		}
+	}
+	dec.start = dec.pos
+	return dec.nextObject(reflect.Indirect(val))
+}
+
+// loadArena copies the next document in the stream into the arena.
+func (dec *Decoder) loadArena() error {
+	start := dec.pos
+	err := dec.skip()
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("nil destination")
+	dec.base = start
+	dec.abytes = append([]byte(nil), dec.stream[start:dec.pos]...)
+	dec.astr = string(dec.abytes)
+	dec.pos = start
+	return nil
 }
 
 var (
@@ -109,23 +340,31 @@ func (dec *Decoder) DecodeAll(res []interface{}) ([]interface{}, error) {
 }
 */
 
-//fetch the next object at position 'pos' in 'stream'
-func (self *Decoder) nextObject(val reflect.Value) error {
-	if self.pos >= len(self.stream) {
+// nextObject decodes the next object in the stream into val.
+func (dec *Decoder) nextObject(val reflect.Value) error {
+	if dec.pos >= len(dec.stream) {
 		return EOF
 	}
-	switch c := self.stream[self.pos]; c {
+	if u, ok := unmarshaller(val); ok {
+		start := dec.pos
+		err := dec.skip()
+		if err != nil {
+			return err
+		}
+		return u.UnmarshalBencoding(dec.stream[start:dec.pos])
+	}
+	switch c := dec.stream[dec.pos]; c {
 	case 'i':
-		return self.nextInteger(val)
+		return dec.nextInteger(val)
 	case 'l':
-		return self.nextList(val)
+		return dec.nextList(val)
 	case 'd':
-		return self.nextDict(val)
+		return dec.nextDict(val)
 	default:
 		if c >= '0' && c <= '9' {
-			return self.nextString(val)
+			return dec.nextString(val)
 		}
-		return fmt.Errorf("couldn't parse '%s' index %d (%s)", self.stream, self.pos, string(self.stream[self.pos]))
+		return fmt.Errorf("unexpected byte %x at offset %d", c, dec.pos)
 	}
 }
 
@@ -147,157 +386,136 @@ var okInt = map[reflect.Kind]bool{
 	reflect.Bool:       true,
 }
 
-//fetches next integer from stream and advances pos pointer
+// nextInteger decodes an integer into val.
 func (dec *Decoder) nextInteger(val reflect.Value) error {
-	if dec.pos >= len(dec.stream) {
-		return EOF
-	}
-
-	if dec.stream[dec.pos] != 'i' {
-		return fmt.Errorf("not an integer")
-	}
-	dec.pos++
-
 	typ := derefType(val.Type())
 	kind := typ.Kind()
 	if ok := okInt[kind] || isEmptyInterface(typ); !ok {
-		return fmt.Errorf("cannot decode integer to %T", val.Interface())
+		return fmt.Errorf("cannot decode integer to %v", val.Type())
 	}
-
-	var neg bool
-	if dec.pos >= len(dec.stream) {
-		return fmt.Errorf("unterminated integer")
-	}
-	if dec.stream[dec.pos] == '-' {
-		neg = true
-		dec.pos++
-	}
-	start := dec.pos
-
-	i := bytes.IndexFunc(dec.stream[dec.pos:], func(c rune) bool {
-		return c < '0' || c > '9'
-	})
-	if i < 0 {
-		return fmt.Errorf("unterminated integer")
+	neg, digits, err := dec.scanInteger()
+	if err != nil {
+		return err
 	}
-	dec.pos += i
-	if dec.stream[dec.pos] != 'e' {
-		return fmt.Errorf("unexpected byte %x", dec.stream[dec.pos])
+	if kind == reflect.Interface && dec.usenumber {
+		val, _ = derefVal(val, true)
+		val.Set(reflect.ValueOf(number(neg, digits)))
+		return nil
+	}
+	mag, ok := parseMagnitude(digits)
+	if !ok {
+		return fmt.Errorf("integer %s out of range", digits)
+	}
+
+	// the magnitude limit of the destination, for a positive or negative
+	// value.
+	var max uint64
+	switch {
+	case kind == reflect.Bool:
+		max = 1<<8 - 1
+	case kind == reflect.Interface:
+		max = 1<<63 - 1
+	case intKind[kind]:
+		max = 1<<uint(typ.Bits()-1) - 1
+	case uintKind[kind]:
+		max = 1<<uint(typ.Bits()) - 1
+	default:
+		max = 1<<64 - 1
 	}
-	intstr := string(dec.stream[start:dec.pos])
-	dec.pos++
-	if len(intstr) == 0 {
-		return fmt.Errorf("unexpected integer terminator")
+	if neg && (kind == reflect.Interface || intKind[kind]) {
+		max++
+	} else if neg && (kind == reflect.Bool || uintKind[kind]) {
+		max = 0
 	}
-	if intstr[0] == '0' {
-		if len(intstr) == 1 && neg {
-			return fmt.Errorf("invalid integer -0")
-		}
-		if len(intstr) > 1 {
-			return fmt.Errorf("leading zero")
+	if mag > max {
+		if neg {
+			return fmt.Errorf("integer -%s out of range for %v", digits, typ)
 		}
+		return fmt.Errorf("integer %s out of range for %v", digits, typ)
 	}
-	var bits int
+	x := int64(mag)
 	if neg {
-		intstr = "-" + intstr
+		x = -x
 	}
-	if kind == reflect.Bool {
-		x, err := strconv.ParseUint(intstr, 10, 8)
-		if err != nil {
-			return err
-		}
-		val, _ = derefVal(val, true)
-		val.SetBool(x != 0)
-	} else if kind == reflect.Interface {
-		x, err := strconv.ParseInt(intstr, 10, 64)
-		if err != nil {
-			return err
-		}
-		val, _ = derefVal(val, true)
+
+	val, _ = derefVal(val, true)
+	switch {
+	case kind == reflect.Bool:
+		val.SetBool(mag != 0)
+	case kind == reflect.Interface:
 		val.Set(reflect.ValueOf(x))
-	} else if intKind[kind] {
-		bits = typ.Bits()
-		x, err := strconv.ParseInt(intstr, 10, bits)
-		if err != nil {
-			return err
-		}
-		val, _ = derefVal(val, true)
+	case intKind[kind]:
 		val.SetInt(x)
-	} else if uintKind[kind] {
-		bits = typ.Bits()
-		x, err := strconv.ParseUint(intstr, 10, bits)
-		if err != nil {
-			return err
-		}
-		val, _ = derefVal(val, true)
-		val.SetUint(x)
+	case uintKind[kind]:
+		val.SetUint(mag)
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		val.SetFloat(float64(x))
+	default:
+		val.SetComplex(complex(float64(x), 0))
 	}
 	return nil
 }
 
-//fetches next string from stream and advances pos pointer
+// nextString decodes a string into val.
 func (dec *Decoder) nextString(val reflect.Value) error {
-	if dec.pos >= len(dec.stream) {
-		return EOF
-	}
-	if dec.stream[dec.pos] < '0' || dec.stream[dec.pos] > '9' {
-		return fmt.Errorf("not a string")
-	}
 	typ := derefType(val.Type())
 	byteslice := typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
-	if ok := typ.Kind() == reflect.String || byteslice || isEmptyInterface(typ); !ok {
-		return fmt.Errorf("cannot decode string to %T", val.Interface())
+	bytearray := typ.Kind() == reflect.Array && typ.Elem().Kind() == reflect.Uint8
+	if ok := typ.Kind() == reflect.String || byteslice || bytearray || isEmptyInterface(typ); !ok {
+		return fmt.Errorf("cannot decode string to %v", val.Type())
 	}
-
-	// scan length
-	start := dec.pos
-	i := bytes.IndexFunc(dec.stream[start:], func(c rune) bool {
-		return c < '0' || c > '9'
-	})
-	if i < 0 {
-		return fmt.Errorf("unterminated string length specifier")
-	}
-	dec.pos += i
-	if dec.stream[dec.pos] != ':' {
-		return fmt.Errorf("unexpected byte %x", dec.stream[dec.pos])
-	}
-	slen, err := strconv.Atoi(string(dec.stream[start:dec.pos]))
+	p, err := dec.scanString()
 	if err != nil {
 		return err
 	}
-	dec.pos++
-
-	// slice data
-	if slen > len(dec.stream[dec.pos:]) {
-		return fmt.Errorf("unexpected end of string")
-	}
-	res := string(dec.stream[dec.pos : dec.pos+slen])
-	dec.pos += slen
 
 	val, _ = derefVal(val, true)
+	if bytearray {
+		if len(p) != typ.Len() {
+			return fmt.Errorf("cannot decode %d byte string to %v", len(p), val.Type())
+		}
+		reflect.Copy(val, reflect.ValueOf(p))
+		return nil
+	}
+	if byteslice && dec.zerocopy {
+		val.Set(reflect.ValueOf(p[:len(p):len(p)]).Convert(typ))
+		return nil
+	}
+	if dec.arena {
+		end := dec.pos - dec.base
+		start := end - len(p)
+		switch {
+		case byteslice:
+			b := reflect.ValueOf(dec.abytes[start:end:end])
+			val.Set(b.Convert(typ))
+		case typ.Kind() == reflect.Interface:
+			val.Set(reflect.ValueOf(dec.astr[start:end]))
+		default:
+			val.SetString(dec.astr[start:end])
+		}
+		return nil
+	}
 	if byteslice {
-		val.Set(reflect.ValueOf([]byte(res)))
+		b := reflect.MakeSlice(typ, len(p), len(p))
+		reflect.Copy(b, reflect.ValueOf(p))
+		val.Set(b)
 	} else if typ.Kind() == reflect.Interface {
-		val.Set(reflect.ValueOf(res))
+		val.Set(reflect.ValueOf(string(p)))
 	} else {
-		val.SetString(res)
+		val.SetString(string(p))
 	}
 	return nil
 }
 
-//fetches a list (and its contents) from stream and advances pos
+// nextList decodes a list (and its contents) into val.
 func (dec *Decoder) nextList(val reflect.Value) error {
-	if dec.pos >= len(dec.stream) {
-		return EOF
-	}
 	typ := derefType(val.Type())
+	if typ.Kind() == reflect.Array {
+		return dec.nextArray(val)
+	}
 	emptyiface := isEmptyInterface(typ)
 	if !emptyiface && typ.Kind() != reflect.Slice {
-		return fmt.Errorf("cannot decode list to %T", val.Interface())
-	}
-
-	if dec.stream[dec.pos] != 'l' {
-		return fmt.Errorf("not a list")
+		return fmt.Errorf("cannot decode list to %v", val.Type())
 	}
 	dec.pos++ //skip 'l'
 
@@ -307,9 +525,21 @@ func (dec *Decoder) nextList(val reflect.Value) error {
 		var s []interface{}
 		sval = reflect.Indirect(reflect.ValueOf(&s))
 		typ = sval.Type()
+	} else if n := dec.countList(); n > 0 {
+		// decode elements in place; the count guarantees the list is
+		// well formed up to its terminator.
+		sval = reflect.MakeSlice(typ, n, n)
+		for i := 0; i < n; i++ {
+			err := dec.nextObject(sval.Index(i).Addr())
+			if err != nil {
+				return err
+			}
+		}
+		dec.pos++ //skip 'e'
 		val.Set(sval)
+		return nil
 	} else {
-		sval = val
+		sval = reflect.Zero(typ)
 	}
 
 	for {
@@ -318,6 +548,7 @@ func (dec *Decoder) nextList(val reflect.Value) error {
 		}
 		if dec.stream[dec.pos] == 'e' {
 			dec.pos++ //skip 'e'
+			val.Set(sval)
 			return nil
 		}
 		elem := reflect.New(typ.Elem())
@@ -325,27 +556,71 @@ func (dec *Decoder) nextList(val reflect.Value) error {
 		if err != nil {
 			return err
 		}
-		sval.Set(reflect.Append(sval, reflect.Indirect(elem)))
+		sval = reflect.Append(sval, elem.Elem())
 	}
-	panic("unreachable")
 }
 
-//fetches a dict
-//bencoded dicts must have their keys sorted lexically. but I guess
-//we can ignore that and work with unsorted maps. (wtf?! sorted maps ...)
-func (dec *Decoder) nextDict(val reflect.Value) error {
-	if dec.pos >= len(dec.stream) {
-		return EOF
+// nextArray decodes a list into the array val.  The list must have exactly
+// one element for each element of the array.
+func (dec *Decoder) nextArray(val reflect.Value) error {
+	dec.pos++ //skip 'l'
+	val, _ = derefVal(val, true)
+	n := val.Len()
+	for i := 0; ; i++ {
+		if dec.pos >= len(dec.stream) {
+			return fmt.Errorf("unterminated list")
+		}
+		if dec.stream[dec.pos] == 'e' {
+			if i != n {
+				return fmt.Errorf("cannot decode %d element list to %v", i, val.Type())
+			}
+			dec.pos++ //skip 'e'
+			return nil
+		}
+		if i >= n {
+			return fmt.Errorf("too many list elements for %v", val.Type())
+		}
+		err := dec.nextObject(val.Index(i).Addr())
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// countList returns the number of elements in the list beginning at the
+// decoder's position (after its 'l') when slices are pre-sized.  Zero is
+// returned if pre-sizing is disabled or the list is malformed, in which case
+// the list is decoded by appending.
+func (dec *Decoder) countList() int {
+	if !dec.presize {
+		return 0
+	}
+	pos := dec.pos
+	defer func() { dec.pos = pos }()
+	n := 0
+	for dec.pos < len(dec.stream) {
+		if dec.stream[dec.pos] == 'e' {
+			return n
+		}
+		if dec.skip() != nil {
+			return 0
+		}
+		n++
 	}
+	return 0
+}
+
+// nextDict decodes a dictionary into val.
+//
+// bencoded dicts must have their keys sorted lexically. but I guess
+// we can ignore that and work with unsorted maps. (wtf?! sorted maps ...)
+func (dec *Decoder) nextDict(val reflect.Value) error {
 	var emptyiface bool
 	typ := derefType(val.Type())
 	if typ.Kind() == reflect.Map {
-		if typ.Key().Kind() != reflect.String {
-			return fmt.Errorf("1 cannot decode dictionary to %T", val.Interface())
-		}
-		vtyp := derefType(typ.Elem())
-		if !isEmptyInterface(vtyp) {
-			return fmt.Errorf("2 cannot decode dictionary to %T %v", val.Interface())
+		kind := typ.Key().Kind()
+		if kind != reflect.String && !reflect.PtrTo(typ.Key()).Implements(textUnmarshalerType) {
+			return fmt.Errorf("cannot decode dictionary to %v", val.Type())
 		}
 	} else if isEmptyInterface(typ) {
 		emptyiface = true
@@ -353,12 +628,9 @@ func (dec *Decoder) nextDict(val reflect.Value) error {
 	} else if typ.Kind() == reflect.Struct {
 		return dec.nextDictStruct(val)
 	} else {
-		return fmt.Errorf("3 cannot decode dictionary to %T", val.Interface())
-	}
-
-	if dec.stream[dec.pos] != 'd' {
-		return fmt.Errorf("not a dict")
+		return fmt.Errorf("cannot decode dictionary to %v", val.Type())
 	}
+	top := dec.pos == dec.start
 	dec.pos++ //skip 'd'
 
 	var mval reflect.Value // a value that doesn't have an interface type
@@ -379,35 +651,42 @@ func (dec *Decoder) nextDict(val reflect.Value) error {
 			dec.pos++ //skip 'e'
 			return nil
 		}
-		key := reflect.New(typ.Key())
-		err := dec.nextString(key)
+		k, err := dec.scanString()
 		if err != nil {
 			return err
 		}
+		key := reflect.New(typ.Key()).Elem()
+		if key.Kind() == reflect.String {
+			key.SetString(string(k))
+		} else {
+			err = key.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(k)
+			if err != nil {
+				return err
+			}
+		}
 		elem := reflect.New(typ.Elem())
+		start, hook := dec.pos, dec.hook(top, k)
 		err = dec.nextObject(elem)
 		if err != nil {
 			return err
 		}
-		mval.SetMapIndex(reflect.Indirect(key), reflect.Indirect(elem))
+		if hook != nil {
+			hook(dec.stream[start:dec.pos])
+		}
+		mval.SetMapIndex(key, elem.Elem())
 	}
-
-	panic("unreachable")
 }
 
+// nextDictStruct decodes a dictionary into the struct val.  Keys without a
+// corresponding field are skipped.
 func (dec *Decoder) nextDictStruct(val reflect.Value) error {
-	if dec.stream[dec.pos] != 'd' {
-		return fmt.Errorf("not a dict")
-	}
+	top := dec.pos == dec.start
 	dec.pos++ //skip 'd'
 
 	typ := derefType(val.Type())
 	fs := structFields(typ)
+	val, _ = derefVal(val, true)
 
-	var derref bool
-
-	// a value that definitely does not have an interface type
-	i := 0
 	for {
 		if dec.pos >= len(dec.stream) {
 			return fmt.Errorf("unterminated dictionary")
@@ -416,45 +695,32 @@ func (dec *Decoder) nextDictStruct(val reflect.Value) error {
 			dec.pos++ //skip 'e'
 			return nil
 		}
-		name := reflect.New(reflect.TypeOf(""))
-		err := dec.nextString(name)
+		name, err := dec.scanString()
 		if err != nil {
 			return err
 		}
-		set := false
-		var fval reflect.Value
-		var namestr string = reflect.Indirect(name).String()
-		for j := i; j < len(fs); j++ {
-			if namestr == fs[j].name {
+		// keys are matched without assuming they are sorted or that
+		// every field is present.
+		i := -1
+		for j := range fs {
+			if string(name) == fs[j].name {
 				i = j
-				set = true
-				fval = reflect.New(typ.Field(fs[i].i).Type)
-				break
-			}
-			if !fs[i].omitempty {
 				break
 			}
 		}
-		if !set {
-			var v interface{}
-			fval = reflect.ValueOf(&v)
+		start, hook := dec.pos, dec.hook(top, name)
+		if i < 0 {
+			err = dec.skip()
+		} else {
+			err = dec.nextObject(val.Field(fs[i].i).Addr())
 		}
-		err = dec.nextObject(fval)
 		if err != nil {
 			return err
 		}
-		if set {
-			if !derref {
-				derref = true
-				val, _ = derefVal(val, true)
-			}
-			field := val.Field(fs[i].i)
-			field.Set(reflect.Indirect(fval))
-			i++
+		if hook != nil {
+			hook(dec.stream[start:dec.pos])
 		}
 	}
-
-	panic("unreachable")
 }
 
 func derefKind(val reflect.Value) reflect.Kind {
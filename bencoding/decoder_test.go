@@ -40,6 +40,12 @@ func TestInteger(t *testing.T) {
 	it(t, "i-e", 0, true)
 	it(t, "i15155", 0, true)
 	it(t, "55", 55, true)
+	it(t, "i-0e", 0, true)
+	it(t, "i03e", 0, true)
+	it(t, "i9223372036854775807e", 9223372036854775807, false)
+	it(t, "i-9223372036854775808e", -9223372036854775808, false)
+	it(t, "i9223372036854775808e", 0, true)
+	it(t, "i99999999999999999999999e", 0, true)
 }
 
 func st(t *testing.T, in string, exp string, exp_err bool) {
@@ -146,6 +152,10 @@ func TestUnmarshal_success(t *testing.T) {
 		Ignore string `bencoding:"-"`
 	}
 	type mystring string
+	type raw struct {
+		A RawMessage `bencoding:"a"`
+		B string     `bencoding:"b"`
+	}
 	for _, test := range []struct {
 		benc   string
 		dst    interface{}
@@ -158,10 +168,23 @@ func TestUnmarshal_success(t *testing.T) {
 		{"i3e", new(int32), int32(3)},
 		{"li3e4:boome", new([]interface{}), []interface{}{int64(3), "boom"}},
 		{"le", new(interface{}), []interface{}(nil)},
+		{"li3e4:boome", new(interface{}), []interface{}{int64(3), "boom"}},
 		{"de", new(interface{}), map[string]interface{}{}},
 		{"d5:helloi0ee", new(interface{}), map[string]interface{}{"hello": int64(0)}},
 		{"d5:hello5:worlde", new(map[string]interface{}), map[string]interface{}{"hello": "world"}},
 		{"d6:Ignore5:WORLD3:Pri3:!!!5:hello5:worlde", new(hello), hello{"world", "!!!", ""}},
+		{"d5:hello5:world3:Pri3:!!!e", new(hello), hello{"world", "!!!", ""}},
+		{"d3:Pri3:!!!e", new(hello), hello{"", "!!!", ""}},
+		{"d1:ad1:bi1eee", new(RawMessage), RawMessage("d1:ad1:bi1eee")},
+		{"d1:ad1:bi1ee1:b0:e", new(map[string]RawMessage), map[string]RawMessage{
+			"a": RawMessage("d1:bi1ee"),
+			"b": RawMessage("0:"),
+		}},
+		{"d1:ali1ei2ee1:b1:ce", new(raw), raw{RawMessage("li1ei2ee"), "c"}},
+		{"4:abcd", new([4]byte), [4]byte{'a', 'b', 'c', 'd'}},
+		{"li1ei2ee", new([2]int), [2]int{1, 2}},
+		{"ll1:ael1:bee", new([2][]string), [2][]string{{"a"}, {"b"}}},
+		{"d3:0,5i1e3:1,2i3ee", new(map[pointKey]int), map[pointKey]int{{1, 2}: 3, {0, 5}: 1}},
 	} {
 		err := Unmarshal([]byte(test.benc), test.dst)
 		if err != nil {
@@ -174,3 +197,251 @@ func TestUnmarshal_success(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshal_range(t *testing.T) {
+	for _, test := range []struct {
+		benc string
+		dst  interface{}
+		ok   bool
+	}{
+		{"i127e", new(int8), true},
+		{"i128e", new(int8), false},
+		{"i-128e", new(int8), true},
+		{"i-129e", new(int8), false},
+		{"i255e", new(uint8), true},
+		{"i256e", new(uint8), false},
+		{"i-1e", new(uint), false},
+		{"i18446744073709551615e", new(uint64), true},
+		{"i18446744073709551616e", new(uint64), false},
+		{"i-1e", new(bool), false},
+		{"i3e", new(float64), true},
+		{"3:abc", new([4]byte), false},
+		{"5:abcde", new([4]byte), false},
+		{"li1ee", new([2]int), false},
+		{"li1ei2ei3ee", new([2]int), false},
+		{"d1:xi1ee", new(map[pointKey]int), false},
+		{"d1:xi1ee", new(map[[2]int]int), false},
+	} {
+		err := Unmarshal([]byte(test.benc), test.dst)
+		if test.ok && err != nil {
+			t.Errorf("unmarshal %q -> %T: %v", test.benc, test.dst, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("unmarshal %q -> %T: expected error", test.benc, test.dst)
+		}
+	}
+}
+
+func TestDecoder_UseArena(t *testing.T) {
+	type mybytes []byte
+	type doc struct {
+		A string      `bencoding:"a"`
+		B []byte      `bencoding:"b"`
+		C mybytes     `bencoding:"c"`
+		D interface{} `bencoding:"d"`
+	}
+	in := []byte("d1:a3:one1:b3:two1:c5:three1:dl4:foureed1:a4:five1:b3:sixe")
+	dec := NewDecoderBytes(in)
+	dec.UseArena()
+	var docs []doc
+	for {
+		var d doc
+		err := dec.Decode(&d)
+		if err == EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, d)
+	}
+	for i := range in {
+		in[i] = 'x'
+	}
+	expect := []doc{
+		{"one", []byte("two"), mybytes("three"), []interface{}{"four"}},
+		{"five", []byte("six"), nil, nil},
+	}
+	if !reflect.DeepEqual(docs, expect) {
+		t.Errorf("decoded %q (expected %q)", docs, expect)
+	}
+	if cap(docs[0].B) != len(docs[0].B) {
+		t.Errorf("byte slice capacity %d exceeds its length %d", cap(docs[0].B), len(docs[0].B))
+	}
+}
+
+func BenchmarkDecoder_arena(b *testing.B) {
+	benchmarkDecoder(b, true)
+}
+
+func BenchmarkDecoder_noArena(b *testing.B) {
+	benchmarkDecoder(b, false)
+}
+
+func benchmarkDecoder(b *testing.B, arena bool) {
+	type doc struct {
+		Name  string   `bencoding:"name"`
+		Hash  []byte   `bencoding:"hash"`
+		Files []string `bencoding:"files"`
+	}
+	p := []byte("d5:filesl5:a.txt5:b.txt5:c.txte4:hash20:01234567890123456789" +
+		"4:name7:examplee")
+	b.SetBytes(int64(len(p)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoderBytes(p)
+		if arena {
+			dec.UseArena()
+		}
+		var d doc
+		err := dec.Decode(&d)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoder_Hook(t *testing.T) {
+	type doc struct {
+		A interface{} `bencoding:"a"`
+	}
+	in := "d1:ad1:bi1ee1:bli2ee1:c1:xe"
+	for _, dst := range []interface{}{new(doc), new(map[string]interface{})} {
+		raw := make(map[string]string)
+		dec := NewDecoderBytes([]byte(in))
+		for _, key := range []string{"a", "b", "z"} {
+			key := key
+			dec.Hook(key, func(p []byte) { raw[key] = string(p) })
+		}
+		err := dec.Decode(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := map[string]string{"a": "d1:bi1ee", "b": "li2ee"}
+		if !reflect.DeepEqual(raw, expect) {
+			t.Errorf("%T: hooked %q (expected %q)", dst, raw, expect)
+		}
+	}
+}
+
+func TestDecoder_PresizeSlices(t *testing.T) {
+	type peer struct {
+		IP   string `bencoding:"ip"`
+		Port int    `bencoding:"port"`
+	}
+	for _, test := range []struct {
+		benc string
+		dst  func() interface{}
+		ok   bool
+	}{
+		{"le", func() interface{} { return new([]int) }, true},
+		{"li1ei2ei3ee", func() interface{} { return new([]int) }, true},
+		{"ll1:aeli1eee", func() interface{} { return new([][]interface{}) }, true},
+		{"ld2:ip1:a4:porti1eed2:ip1:b4:porti2eee", func() interface{} { return new([]peer) }, true},
+		{"li1ei2e", func() interface{} { return new([]int) }, false},
+		{"li1e1:ae", func() interface{} { return new([]int) }, false},
+		{"li1ex", func() interface{} { return new([]int) }, false},
+	} {
+		expect := test.dst()
+		experr := NewDecoderBytes([]byte(test.benc)).Decode(expect)
+		dst := test.dst()
+		dec := NewDecoderBytes([]byte(test.benc))
+		dec.PresizeSlices()
+		err := dec.Decode(dst)
+		if (err == nil) != test.ok || (experr == nil) != test.ok {
+			t.Errorf("decode %q: %v (default %v)", test.benc, err, experr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(dst, expect) {
+			t.Errorf("decode %q: %v (expected %v)", test.benc, dst, expect)
+		}
+	}
+}
+
+func BenchmarkDecoder_presize(b *testing.B) {
+	benchmarkDecoderList(b, true)
+}
+
+func BenchmarkDecoder_append(b *testing.B) {
+	benchmarkDecoderList(b, false)
+}
+
+func benchmarkDecoderList(b *testing.B, presize bool) {
+	p := []byte("l")
+	for i := 0; i < 1000; i++ {
+		p = append(p, "d2:ip9:127.0.0.14:porti6881ee"...)
+	}
+	p = append(p, 'e')
+	type peer struct {
+		IP   string `bencoding:"ip"`
+		Port int    `bencoding:"port"`
+	}
+	b.SetBytes(int64(len(p)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoderBytes(p)
+		if presize {
+			dec.PresizeSlices()
+		}
+		var peers []peer
+		err := dec.Decode(&peers)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoder_Reset(t *testing.T) {
+	dec := NewDecoderBytes([]byte("1:a"))
+	dec.UseArena()
+	var hooked []string
+	dec.Hook("k", func(p []byte) { hooked = append(hooked, string(p)) })
+	var got []string
+	for _, msg := range []string{"d1:k1:ae", "d1:k1:be", "d1:k2:cce"} {
+		dec.Reset([]byte(msg))
+		var v map[string]string
+		err := dec.Decode(&v)
+		if err != nil {
+			t.Fatalf("decode %q: %v", msg, err)
+		}
+		got = append(got, v["k"])
+		var rest interface{}
+		if err := dec.Decode(&rest); err != EOF {
+			t.Errorf("decode %q: %v after message (expected EOF)", msg, err)
+		}
+	}
+	expect := []string{"a", "b", "cc"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("decoded %q (expected %q)", got, expect)
+	}
+	if !reflect.DeepEqual(hooked, []string{"1:a", "1:b", "2:cc"}) {
+		t.Errorf("hooked %q", hooked)
+	}
+}
+
+func TestDecoder_UseZeroCopy(t *testing.T) {
+	in := []byte("l3:abc3:defe")
+	var v []interface{}
+	var b [][]byte
+	dec := NewDecoderBytes(in)
+	dec.UseZeroCopy()
+	err := dec.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec.Reset(in)
+	err = dec.Decode(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in[3] = 'A'
+	if string(b[0]) != "Abc" {
+		t.Errorf("byte slice %q does not alias the input", b[0])
+	}
+	if v[0] != "abc" {
+		t.Errorf("string %q aliases the input", v[0])
+	}
+	if cap(b[0]) != 3 {
+		t.Errorf("byte slice capacity %d (expected 3)", cap(b[0]))
+	}
+}
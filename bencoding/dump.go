@@ -0,0 +1,119 @@
+package bencoding
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDumpBytes is the length above which Dump summarizes binary strings
+// instead of printing them in hex.
+const maxDumpBytes = 64
+
+// Get returns the element of v, a value decoded into an interface{}, named
+// by path.  Path is a sequence of dictionary keys and list indices separated
+// by dots, as in "info.files.0.path".  An empty path returns v.
+func Get(v interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, elem := range strings.Split(path, ".") {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			v, ok = x[elem]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found", elem)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil {
+				return nil, fmt.Errorf("invalid list index %q", elem)
+			}
+			if i < 0 || i >= len(x) {
+				return nil, fmt.Errorf("list index %d out of range", i)
+			}
+			v = x[i]
+		default:
+			return nil, fmt.Errorf("cannot index %T with %q", v, elem)
+		}
+	}
+	return v, nil
+}
+
+// Dump writes a human readable representation of v, a value decoded into an
+// interface{}, to w.  Dictionaries are printed with sorted keys.  Strings
+// that are not valid UTF-8 are printed in hex, or summarized by length if
+// they are long.
+func Dump(w io.Writer, v interface{}) error {
+	bw := bufio.NewWriter(w)
+	err := dump(bw, v, "")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(bw)
+	return bw.Flush()
+}
+
+func dump(w *bufio.Writer, v interface{}, indent string) error {
+	switch x := v.(type) {
+	case int64:
+		fmt.Fprint(w, x)
+	case Number:
+		w.WriteString(string(x))
+	case string:
+		w.WriteString(dumpString(x))
+	case []interface{}:
+		if len(x) == 0 {
+			w.WriteString("[]")
+			return nil
+		}
+		w.WriteString("[\n")
+		for _, elem := range x {
+			w.WriteString(indent + "  ")
+			err := dump(w, elem, indent+"  ")
+			if err != nil {
+				return err
+			}
+			w.WriteString("\n")
+		}
+		w.WriteString(indent + "]")
+	case map[string]interface{}:
+		if len(x) == 0 {
+			w.WriteString("{}")
+			return nil
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		w.WriteString("{\n")
+		for _, k := range keys {
+			w.WriteString(indent + "  " + dumpString(k) + ": ")
+			err := dump(w, x[k], indent+"  ")
+			if err != nil {
+				return err
+			}
+			w.WriteString("\n")
+		}
+		w.WriteString(indent + "}")
+	default:
+		return fmt.Errorf("cannot dump %T", v)
+	}
+	return nil
+}
+
+func dumpString(s string) string {
+	if utf8.ValidString(s) {
+		return strconv.Quote(s)
+	}
+	if len(s) > maxDumpBytes {
+		return fmt.Sprintf("<%d bytes>", len(s))
+	}
+	return fmt.Sprintf("<%x>", s)
+}
@@ -0,0 +1,71 @@
+package bencoding
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	var v interface{}
+	err := Unmarshal([]byte("d4:infod5:filesld6:lengthi3e4:pathl1:a1:beee4:name1:xee"), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path   string
+		expect interface{}
+		err    bool
+	}{
+		{"info.name", "x", false},
+		{"info.files.0.length", int64(3), false},
+		{"info.files.0.path.1", "b", false},
+		{"info.files.1", nil, true},
+		{"info.files.x", nil, true},
+		{"info.missing", nil, true},
+		{"info.name.0", nil, true},
+	} {
+		x, err := Get(v, test.path)
+		if test.err {
+			if err == nil {
+				t.Errorf("get %q: expected error", test.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("get %q: %v", test.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(x, test.expect) {
+			t.Errorf("get %q got %#v (expect %#v)", test.path, x, test.expect)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	for _, test := range []struct {
+		in     string
+		expect string
+	}{
+		{"i-3e", "-3\n"},
+		{"le", "[]\n"},
+		{"3:\x00\x01\xff", "<0001ff>\n"},
+		{"d1:bli1e1:ce1:ad1:x0:ee", "{\n  \"a\": {\n    \"x\": \"\"\n  }\n  \"b\": [\n    1\n    \"c\"\n  ]\n}\n"},
+	} {
+		var v interface{}
+		err := Unmarshal([]byte(test.in), &v)
+		if err != nil {
+			t.Errorf("unmarshal %q: %v", test.in, err)
+			continue
+		}
+		var buf bytes.Buffer
+		err = Dump(&buf, v)
+		if err != nil {
+			t.Errorf("dump %q: %v", test.in, err)
+			continue
+		}
+		if buf.String() != test.expect {
+			t.Errorf("dump %q got %q (expect %q)", test.in, buf.String(), test.expect)
+		}
+	}
+}
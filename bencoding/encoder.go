@@ -1,25 +1,28 @@
 package bencoding
 
 import (
+	"encoding"
 	"fmt"
 	"io"
 	"reflect"
 	"sort"
+	"strconv"
 )
 
 // Encoder writes bencoded objects into an io.Writer.
 type Encoder struct {
-	w io.Writer //the result byte stream
+	w   io.Writer //the result byte stream
+	buf []byte    // reused between calls to Encode
 }
 
 // NewEncoder allocates and returns an Encoder.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w}
+	return &Encoder{w: w}
 }
 
 // Marshal wraps Encoder.Encode.
 func Marshal(in interface{}) ([]byte, error) {
-	return encodeObject(in, false)
+	return appendObject(nil, in, false)
 }
 
 // Marshaller implements custom marshalling of Bencoded values.
@@ -27,14 +30,19 @@ type Marshaller interface {
 	MarshalBencoding() ([]byte, error)
 }
 
+var marshallerType = reflect.TypeOf((*Marshaller)(nil)).Elem()
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
 // Encode bencodes an object and writes it to enc's output stream.  If v
 // implements Marshaller, v.Marshaller() is written to the output stream.
 // Otherwise a default encoding is of v is performed using runtime reflection.
 func (enc *Encoder) Encode(v interface{}) error {
-	p, err := encodeObject(v, false)
+	p, err := appendObject(enc.buf[:0], v, false)
 	if err != nil {
 		return err
 	}
+	enc.buf = p
 	_, err = enc.w.Write(p)
 	return err
 }
@@ -54,47 +62,117 @@ var uintKind = map[reflect.Kind]bool{
 	reflect.Uint8:  true,
 }
 
-func encodeObject(in interface{}, omitable bool) ([]byte, error) {
-	if m, ok := in.(Marshaller); ok {
-		return m.MarshalBencoding()
-	}
-	if as, ok := in.([]interface{}); ok {
-		return encodeList(as)
-	}
-	if m, ok := in.(map[string]interface{}); ok {
-		return encodeDict(m)
+// appendObject appends the encoding of in to dst.  Common types are encoded
+// without reflection.
+func appendObject(dst []byte, in interface{}, omitable bool) ([]byte, error) {
+	switch v := in.(type) {
+	case Marshaller:
+		p, err := v.MarshalBencoding()
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, p...), nil
+	case string:
+		return appendString(dst, v), nil
+	case []byte:
+		return appendBytes(dst, v), nil
+	case int:
+		return appendInteger(dst, int64(v)), nil
+	case int64:
+		return appendInteger(dst, v), nil
+	case int32:
+		return appendInteger(dst, int64(v)), nil
+	case int16:
+		return appendInteger(dst, int64(v)), nil
+	case int8:
+		return appendInteger(dst, int64(v)), nil
+	case uint:
+		return appendInteger(dst, int64(v)), nil
+	case uint64:
+		// TODO prevent overflow
+		return appendInteger(dst, int64(v)), nil
+	case uint32:
+		return appendInteger(dst, int64(v)), nil
+	case uint16:
+		return appendInteger(dst, int64(v)), nil
+	case uint8:
+		return appendInteger(dst, int64(v)), nil
+	case bool:
+		return appendBool(dst, v), nil
+	case []string:
+		dst = append(dst, 'l')
+		for _, s := range v {
+			dst = appendString(dst, s)
+		}
+		return append(dst, 'e'), nil
+	case []interface{}:
+		return appendList(dst, v)
+	case map[string]string:
+		return appendStringDict(dst, v), nil
+	case map[string]interface{}:
+		return appendDict(dst, v)
+	case nil:
+		return nil, fmt.Errorf("nil value")
 	}
-	if p, ok := in.([]byte); ok {
-		return encodeBytes(p), nil
+	return appendValue(dst, reflect.ValueOf(in), omitable)
+}
+
+// appendValue appends the encoding of v to dst using reflection.
+func appendValue(dst []byte, v reflect.Value, omitable bool) ([]byte, error) {
+	if v.Type().Implements(marshallerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() && !omitable {
+			return nil, fmt.Errorf("nil value")
+		}
+		return appendObject(dst, v.Interface(), omitable)
 	}
-	t := reflect.TypeOf(in)
-	k := t.Kind()
+	k := v.Kind()
 	switch {
-	case k == reflect.Ptr:
-		val := reflect.ValueOf(in)
-		if val.IsNil() && !omitable {
+	case k == reflect.Ptr || k == reflect.Interface:
+		if v.IsNil() {
+			if omitable {
+				return dst, nil
+			}
 			return nil, fmt.Errorf("nil value")
 		}
-		return encodeObject(reflect.Indirect(val).Interface(), omitable)
+		return appendValue(dst, v.Elem(), omitable)
 	case k == reflect.Struct:
-		return encodeStruct(reflect.ValueOf(in))
+		return appendStruct(dst, v)
+	case k == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		return appendMap(dst, v)
+	case k == reflect.Map && v.Type().Key().Implements(textMarshalerType):
+		return appendTextMap(dst, v)
 	case k == reflect.String:
-		return encodeString(reflect.ValueOf(in).String()), nil
-	case k == reflect.Slice:
-		return encodeSlice(reflect.ValueOf(in))
+		return appendString(dst, v.String()), nil
+	case k == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return appendBytes(dst, v.Bytes()), nil
+	case k == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+		n := v.Len()
+		dst = strconv.AppendInt(dst, int64(n), 10)
+		dst = append(dst, ':')
+		for i := 0; i < n; i++ {
+			dst = append(dst, byte(v.Index(i).Uint()))
+		}
+		return dst, nil
+	case k == reflect.Slice || k == reflect.Array:
+		dst = append(dst, 'l')
+		n := v.Len()
+		for i := 0; i < n; i++ {
+			var err error
+			dst, err = appendValue(dst, v.Index(i), false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, 'e'), nil
 	case intKind[k]:
-		return encodeInteger(reflect.ValueOf(in).Int()), nil
+		return appendInteger(dst, v.Int()), nil
 	case uintKind[k]:
 		// TODO prevent overflow
-		return encodeInteger(int64(reflect.ValueOf(in).Uint())), nil
+		return appendInteger(dst, int64(v.Uint())), nil
 	case k == reflect.Bool:
-		if in.(bool) {
-			return []byte("i1e"), nil
-		} else {
-			return []byte("i0e"), nil
-		}
+		return appendBool(dst, v.Bool()), nil
 	default:
-		return nil, fmt.Errorf("invalid type %T", in)
+		return nil, fmt.Errorf("invalid type %v", v.Type())
 	}
 }
 
@@ -110,108 +188,167 @@ func (fs fields) Less(i, j int) bool { return fs[i].name < fs[j].name }
 func (fs fields) Swap(i, j int)      { fs[i], fs[j] = fs[j], fs[i] }
 
 // BUG: dictionary keys cannot contain commas
-func encodeStruct(v reflect.Value) ([]byte, error) {
-	typ := v.Type()
-	fs := structFields(typ)
-	var benc []byte
-	benc = append(benc, 'd')
+func appendStruct(dst []byte, v reflect.Value) ([]byte, error) {
+	fs := structFields(v.Type())
+	dst = append(dst, 'd')
 	for _, f := range fs {
-		p, err := encodeObject(v.Field(f.i).Interface(), f.omitempty)
+		fv := v.Field(f.i)
+		if f.omitempty && isNil(fv) {
+			continue
+		}
+		mark := len(dst)
+		dst = appendString(dst, f.name)
+		start := len(dst)
+		var err error
+		dst, err = appendValue(dst, fv, f.omitempty)
 		if err != nil {
 			return nil, err
 		}
-		if f.omitempty {
-			if len(p) < 2 {
-				panic("empty byte slice")
-			}
-			switch {
-			case p[0] == '0' && p[1] == ':':
-				continue
-			case p[0] == 'l' && p[1] == 'e':
-				continue
-			case p[0] == 'd' && p[1] == 'e':
-				continue
-			}
+		if f.omitempty && isEmptyEncoding(dst[start:]) {
+			dst = dst[:mark]
 		}
-		namep := encodeString(f.name)
-		benc = append(benc, namep...)
-		benc = append(benc, p...)
 	}
-	benc = append(benc, 'e')
-	return benc, nil
+	return append(dst, 'e'), nil
 }
 
-func encodeString(s string) []byte {
-	if len(s) <= 0 {
-		return []byte{'0', ':'}
+// isEmptyEncoding returns true if p encodes a value omitted by omitempty.
+func isEmptyEncoding(p []byte) bool {
+	switch string(p) {
+	case "", "0:", "le", "de", "i0e":
+		return true
 	}
-	return []byte(fmt.Sprintf("%d:%s", len(s), s))
+	return false
 }
 
-func encodeBytes(p []byte) []byte {
-	if len(p) <= 0 {
-		return []byte{'0', ':'}
+// isNil returns true if v is a nil pointer, interface, slice, or map.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
 	}
-	return []byte(fmt.Sprintf("%d:%s", len(p), p))
+	return false
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = strconv.AppendInt(dst, int64(len(s)), 10)
+	dst = append(dst, ':')
+	return append(dst, s...)
+}
+
+func appendBytes(dst []byte, p []byte) []byte {
+	dst = strconv.AppendInt(dst, int64(len(p)), 10)
+	dst = append(dst, ':')
+	return append(dst, p...)
 }
 
-func encodeInteger(i int64) []byte {
-	return []byte(fmt.Sprintf("i%de", i))
+func appendInteger(dst []byte, i int64) []byte {
+	dst = append(dst, 'i')
+	dst = strconv.AppendInt(dst, i, 10)
+	return append(dst, 'e')
 }
 
-func encodeSlice(val reflect.Value) ([]byte, error) {
-	n := val.Len()
-	if n == 0 {
-		return []byte{'l', 'e'}, nil
+func appendBool(dst []byte, b bool) []byte {
+	if b {
+		return append(dst, "i1e"...)
 	}
-	ret := []byte("l")
-	for i := 0; i < n; i++ {
-		p, err := encodeObject(val.Index(i).Interface(), false)
+	return append(dst, "i0e"...)
+}
+
+func appendList(dst []byte, list []interface{}) ([]byte, error) {
+	dst = append(dst, 'l')
+	for _, obj := range list {
+		var err error
+		dst, err = appendObject(dst, obj, false)
 		if err != nil {
 			return nil, err
 		}
-		ret = append(ret, p...)
 	}
-	ret = append(ret, 'e')
-	return ret, nil
+	return append(dst, 'e'), nil
 }
 
-func encodeList(list []interface{}) ([]byte, error) {
-	if len(list) <= 0 {
-		return []byte{'l', 'e'}, nil
+func appendDict(dst []byte, m map[string]interface{}) ([]byte, error) {
+	dst = append(dst, 'd')
+	for _, k := range sortedKeys(m) {
+		dst = appendString(dst, k)
+		var err error
+		dst, err = appendObject(dst, m[k], false)
+		if err != nil {
+			return nil, err
+		}
 	}
-	ret := []byte("l")
-	for _, obj := range list {
-		p, err := encodeObject(obj, false)
+	return append(dst, 'e'), nil
+}
+
+// appendMap appends a map with string keys, such as a map[string]int64 or a
+// map of a defined string type, in sorted key order.
+func appendMap(dst []byte, v reflect.Value) ([]byte, error) {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	dst = append(dst, 'd')
+	for _, k := range keys {
+		dst = appendString(dst, k.String())
+		var err error
+		dst, err = appendValue(dst, v.MapIndex(k), false)
 		if err != nil {
 			return nil, err
 		}
-		ret = append(ret, p...)
 	}
-	ret = append(ret, 'e')
-	return ret, nil
+	return append(dst, 'e'), nil
 }
 
-func encodeDict(m map[string]interface{}) ([]byte, error) {
-	if len(m) <= 0 {
-		return []byte{'d', 'e'}, nil
+// appendTextMap appends a map whose keys implement encoding.TextMarshaler,
+// using the text of each key as its dictionary key.
+func appendTextMap(dst []byte, v reflect.Value) ([]byte, error) {
+	type entry struct {
+		key string
+		val reflect.Value
 	}
-	//sort the map >.<
+	entries := make([]entry, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return nil, fmt.Errorf("nil map key")
+		}
+		text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{string(text), v.MapIndex(k)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	dst = append(dst, 'd')
+	for i, e := range entries {
+		if i > 0 && e.key == entries[i-1].key {
+			return nil, fmt.Errorf("duplicate map key %q", e.key)
+		}
+		dst = appendString(dst, e.key)
+		var err error
+		dst, err = appendValue(dst, e.val, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, 'e'), nil
+}
+
+func appendStringDict(dst []byte, m map[string]string) []byte {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
-
-	ret := []byte("d")
+	dst = append(dst, 'd')
 	for _, k := range keys {
-		p, err := encodeObject(m[k], false)
-		if err != nil {
-			return nil, err
-		}
-		ret = append(ret, encodeString(k)...)
-		ret = append(ret, p...)
+		dst = appendString(dst, k)
+		dst = appendString(dst, m[k])
 	}
-	ret = append(ret, 'e')
-	return ret, nil
+	return append(dst, 'e')
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -2,9 +2,22 @@ package bencoding
 
 import (
 	"fmt"
+	"io/ioutil"
 	"testing"
 )
 
+// pointKey is a map key type encoded as text.
+type pointKey struct{ X, Y int }
+
+func (k pointKey) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", k.X, k.Y)), nil
+}
+
+func (k *pointKey) UnmarshalText(p []byte) error {
+	_, err := fmt.Sscanf(string(p), "%d,%d", &k.X, &k.Y)
+	return err
+}
+
 func TestMarshal_success(t *testing.T) {
 	type MyString string
 	type MyInt int32
@@ -32,6 +45,33 @@ func TestMarshal_success(t *testing.T) {
 			D string `bencoding:"-"`
 			e int64
 		}{}, "d1:Bi0e1:ci0ee"},
+		{RawMessage("li1ei2ee"), "li1ei2ee"},
+		{struct {
+			A RawMessage `bencoding:"a"`
+			B RawMessage `bencoding:"b,omitempty"`
+			C *int64     `bencoding:"c,omitempty"`
+		}{A: RawMessage("d1:x1:ye")}, "d1:ad1:x1:yee"},
+		{struct {
+			A int64 `bencoding:"a,omitempty"`
+			B bool  `bencoding:"b,omitempty"`
+			C int64 `bencoding:"c,omitempty"`
+		}{C: 1}, "d1:ci1ee"},
+		{[4]byte{'a', 'b', 'c', 'd'}, "4:abcd"},
+		{struct {
+			H [2]uint8 `bencoding:"h"`
+		}{[2]uint8{'x', 'y'}}, "d1:h2:xye"},
+		{[2]int{1, 2}, "li1ei2ee"},
+		{[0]string{}, "le"},
+		{map[pointKey]int{{1, 2}: 3, {0, 5}: 1}, "d3:0,5i1e3:1,2i3ee"},
+		{map[string]int64{"b": 2, "a": 1}, "d1:ai1e1:bi2ee"},
+		{map[string][]string{"x": {"y", "z"}, "": nil}, "d0:le1:xl1:y1:zee"},
+		{map[MyString]MyInt{"b": 2, "B": 1}, "d1:Bi1e1:bi2ee"},
+		{map[string]map[string]bool{"a": {"b": true}}, "d1:ad1:bi1eee"},
+		{map[string]int(nil), "de"},
+		{struct {
+			M map[string]int `bencoding:"m,omitempty"`
+			N map[string]int `bencoding:"n,omitempty"`
+		}{N: map[string]int{}}, "de"},
 	} {
 		p, err := Marshal(test.v)
 		if err != nil {
@@ -51,6 +91,10 @@ func TestMarshal_failure(t *testing.T) {
 	}{
 		{func() { fmt.Println("hello, bencoding") }},
 		{make(chan int)},
+		{map[*pointKey]int{nil: 1}},
+		{map[[2]int]int{{1, 2}: 3}},
+		{map[string]*int{"a": nil}},
+		{map[string]chan int{"a": nil}},
 	} {
 		p, err := Marshal(test.v)
 		if err == nil {
@@ -59,3 +103,41 @@ func TestMarshal_failure(t *testing.T) {
 		}
 	}
 }
+
+func BenchmarkEncoder_string(b *testing.B) {
+	enc := NewEncoder(ioutil.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		enc.Encode("announce")
+	}
+}
+
+func BenchmarkEncoder_int(b *testing.B) {
+	enc := NewEncoder(ioutil.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		enc.Encode(1800)
+	}
+}
+
+func BenchmarkEncoder_stringMap(b *testing.B) {
+	m := map[string]string{"t": "aa", "y": "q", "q": "ping"}
+	enc := NewEncoder(ioutil.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		enc.Encode(m)
+	}
+}
+
+func BenchmarkEncoder_struct(b *testing.B) {
+	v := struct {
+		Interval int64  `bencoding:"interval"`
+		Peers    []byte `bencoding:"peers"`
+		Warning  string `bencoding:"warning message,omitempty"`
+	}{1800, make([]byte, 60), ""}
+	enc := NewEncoder(ioutil.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		enc.Encode(v)
+	}
+}
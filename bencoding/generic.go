@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package bencoding
+
+// UnmarshalAs decodes p, which must contain exactly one bencoded value, as a
+// value of type T.
+func UnmarshalAs[T any](p []byte) (T, error) {
+	var v T
+	err := Unmarshal(p, &v)
+	return v, err
+}
+
+// MarshalValue returns the bencoding of v.  It is equivalent to Marshal but
+// checks the type of v at compile time.
+func MarshalValue[T any](v T) ([]byte, error) {
+	return Marshal(v)
+}
+
+// DecodeAs reads the next value from dec as a value of type T.
+func DecodeAs[T any](dec *Decoder) (T, error) {
+	var v T
+	err := dec.Decode(&v)
+	return v, err
+}
@@ -0,0 +1,50 @@
+//go:build go1.18
+// +build go1.18
+
+package bencoding
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalAs(t *testing.T) {
+	type pair struct {
+		A string `bencoding:"a"`
+		B int    `bencoding:"b"`
+	}
+	v, err := UnmarshalAs[pair]([]byte("d1:a1:x1:bi2ee"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != (pair{"x", 2}) {
+		t.Errorf("decoded %+v", v)
+	}
+	p, err := MarshalValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != "d1:a1:x1:bi2ee" {
+		t.Errorf("encoded %q", p)
+	}
+	_, err = UnmarshalAs[int]([]byte("1:x"))
+	if err == nil {
+		t.Errorf("decoded a string as an int")
+	}
+
+	dec := NewDecoderBytes([]byte("l1:ael1:be"))
+	var lists [][]string
+	for {
+		list, err := DecodeAs[[]string](dec)
+		if err == EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lists = append(lists, list)
+	}
+	if !reflect.DeepEqual(lists, [][]string{{"a"}, {"b"}}) {
+		t.Errorf("decoded %q", lists)
+	}
+}
@@ -0,0 +1,73 @@
+package bencoding
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Number is a bencoded integer in its original decimal form.  A Number can
+// hold integers of any magnitude and is re-encoded exactly as it was
+// decoded.  Integers decode into Number fields, and into interface{} values
+// as Number when Decoder.UseNumber is in effect.
+type Number string
+
+// String returns the decimal text of n.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Int64 returns n as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Uint64 returns n as a uint64.
+func (n Number) Uint64() (uint64, error) {
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+// BigInt returns n as a big.Int.
+func (n Number) BigInt() (*big.Int, error) {
+	x, ok := new(big.Int).SetString(string(n), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", string(n))
+	}
+	return x, nil
+}
+
+// MarshalBencoding encodes n as an integer.
+func (n Number) MarshalBencoding() ([]byte, error) {
+	p := make([]byte, 0, len(n)+2)
+	p = append(p, 'i')
+	p = append(p, n...)
+	p = append(p, 'e')
+	dec := NewDecoderBytes(p)
+	_, _, err := dec.scanInteger()
+	if err != nil || dec.pos < len(p) {
+		return nil, fmt.Errorf("invalid number %q", string(n))
+	}
+	return p, nil
+}
+
+// UnmarshalBencoding decodes an integer into n.
+func (n *Number) UnmarshalBencoding(p []byte) error {
+	dec := NewDecoderBytes(p)
+	neg, digits, err := dec.scanInteger()
+	if err != nil {
+		return err
+	}
+	if dec.pos < len(p) {
+		return fmt.Errorf("trailing bytes")
+	}
+	*n = number(neg, digits)
+	return nil
+}
+
+// number returns the Number with the given sign and digits.
+func number(neg bool, digits []byte) Number {
+	if neg {
+		return Number("-" + string(digits))
+	}
+	return Number(digits)
+}
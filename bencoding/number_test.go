@@ -0,0 +1,69 @@
+package bencoding
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecoder_UseNumber(t *testing.T) {
+	in := "d1:ai-18446744073709551617e1:bli0ei123456789012345678901234567890eee"
+	dec := NewDecoderBytes([]byte(in))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{
+		"a": Number("-18446744073709551617"),
+		"b": []interface{}{Number("0"), Number("123456789012345678901234567890")},
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("decoded %#v (expected %#v)", v, expect)
+	}
+	p, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != in {
+		t.Errorf("encoded %q (expected %q)", p, in)
+	}
+}
+
+func TestNumber_field(t *testing.T) {
+	var v struct {
+		N Number `bencoding:"n"`
+	}
+	err := Unmarshal([]byte("d1:ni18446744073709551615ee"), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.N != "18446744073709551615" {
+		t.Errorf("decoded %q", v.N)
+	}
+	err = Unmarshal([]byte("d1:n1:xe"), &v)
+	if err == nil {
+		t.Errorf("decoded a string into a number")
+	}
+}
+
+func TestNumber_accessors(t *testing.T) {
+	n := Number("18446744073709551615")
+	if _, err := n.Int64(); err == nil {
+		t.Errorf("Int64 did not overflow")
+	}
+	u, err := n.Uint64()
+	if err != nil || u != 1<<64-1 {
+		t.Errorf("Uint64 returned %d, %v", u, err)
+	}
+	x, err := Number("-123456789012345678901234567890").BigInt()
+	if err != nil || x.String() != "-123456789012345678901234567890" {
+		t.Errorf("BigInt returned %v, %v", x, err)
+	}
+	for _, bad := range []Number{"", "-0", "01", "1x", "1e"} {
+		_, err := bad.MarshalBencoding()
+		if err == nil {
+			t.Errorf("encoded invalid number %q", bad)
+		}
+	}
+}
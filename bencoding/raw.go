@@ -0,0 +1,24 @@
+package bencoding
+
+import "fmt"
+
+// RawMessage is a raw encoded bencoding value.  It can be used to delay
+// decoding of a value or to reproduce a value's original encoding exactly.
+type RawMessage []byte
+
+// MarshalBencoding returns m as the encoding of m.
+func (m RawMessage) MarshalBencoding() ([]byte, error) {
+	if len(m) == 0 {
+		return nil, fmt.Errorf("empty raw message")
+	}
+	return m, nil
+}
+
+// UnmarshalBencoding sets *m to a copy of p.
+func (m *RawMessage) UnmarshalBencoding(p []byte) error {
+	if m == nil {
+		return fmt.Errorf("nil raw message")
+	}
+	*m = append((*m)[0:0], p...)
+	return nil
+}
@@ -0,0 +1,128 @@
+package bencoding
+
+import (
+	"fmt"
+)
+
+// maxDigits is the number of decimal digits that always fit in a uint64.
+const maxDigits = 19
+
+// scanDigits advances past a run of decimal digits and returns them.
+func (dec *Decoder) scanDigits() []byte {
+	start := dec.pos
+	for dec.pos < len(dec.stream) {
+		c := dec.stream[dec.pos]
+		if c < '0' || c > '9' {
+			break
+		}
+		dec.pos++
+	}
+	return dec.stream[start:dec.pos]
+}
+
+// scanInteger advances past an integer and returns its sign and digits.  The
+// digits are validated but not converted.
+func (dec *Decoder) scanInteger() (neg bool, digits []byte, err error) {
+	if dec.pos >= len(dec.stream) {
+		return false, nil, EOF
+	}
+	if dec.stream[dec.pos] != 'i' {
+		return false, nil, fmt.Errorf("not an integer")
+	}
+	dec.pos++
+	if dec.pos < len(dec.stream) && dec.stream[dec.pos] == '-' {
+		neg = true
+		dec.pos++
+	}
+	digits = dec.scanDigits()
+	if dec.pos >= len(dec.stream) {
+		return false, nil, fmt.Errorf("unterminated integer")
+	}
+	if dec.stream[dec.pos] != 'e' {
+		return false, nil, fmt.Errorf("unexpected byte %x", dec.stream[dec.pos])
+	}
+	dec.pos++
+	switch {
+	case len(digits) == 0:
+		return false, nil, fmt.Errorf("unexpected integer terminator")
+	case digits[0] == '0' && len(digits) > 1:
+		return false, nil, fmt.Errorf("leading zero")
+	case digits[0] == '0' && neg:
+		return false, nil, fmt.Errorf("invalid integer -0")
+	}
+	return neg, digits, nil
+}
+
+// scanString advances past a string and returns its content, which is a
+// slice of the decoder's input.
+func (dec *Decoder) scanString() ([]byte, error) {
+	if dec.pos >= len(dec.stream) {
+		return nil, EOF
+	}
+	if c := dec.stream[dec.pos]; c < '0' || c > '9' {
+		return nil, fmt.Errorf("not a string")
+	}
+	digits := dec.scanDigits()
+	if dec.pos >= len(dec.stream) {
+		return nil, fmt.Errorf("unterminated string length specifier")
+	}
+	if dec.stream[dec.pos] != ':' {
+		return nil, fmt.Errorf("unexpected byte %x", dec.stream[dec.pos])
+	}
+	dec.pos++
+	n, ok := parseMagnitude(digits)
+	if !ok || n > uint64(len(dec.stream)-dec.pos) {
+		return nil, fmt.Errorf("unexpected end of string")
+	}
+	s := dec.stream[dec.pos : dec.pos+int(n)]
+	dec.pos += int(n)
+	return s, nil
+}
+
+// skip advances past the next value without decoding it.
+func (dec *Decoder) skip() error {
+	depth := 0
+	for {
+		if dec.pos >= len(dec.stream) {
+			if depth > 0 {
+				return fmt.Errorf("unterminated list or dictionary")
+			}
+			return EOF
+		}
+		var err error
+		switch c := dec.stream[dec.pos]; {
+		case c == 'i':
+			_, _, err = dec.scanInteger()
+		case c == 'l' || c == 'd':
+			dec.pos++
+			depth++
+		case c == 'e' && depth > 0:
+			dec.pos++
+			depth--
+		case c >= '0' && c <= '9':
+			_, err = dec.scanString()
+		default:
+			err = fmt.Errorf("unexpected byte %x at offset %d", c, dec.pos)
+		}
+		if err != nil {
+			return err
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// parseMagnitude converts decimal digits to a uint64.  It returns false if
+// the value overflows.
+func parseMagnitude(digits []byte) (uint64, bool) {
+	var n uint64
+	for i, c := range digits {
+		d := uint64(c - '0')
+		if i >= maxDigits && n > (1<<64-1-d)/10 {
+			return 0, false
+		}
+		n = n*10 + d
+	}
+	return n, true
+}
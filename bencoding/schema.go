@@ -0,0 +1,154 @@
+package bencoding
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Kind is the kind of a bencoded value.
+type Kind int
+
+// The kinds of bencoded values.  Any matches values of every kind.
+const (
+	Any Kind = iota
+	Integer
+	String
+	List
+	Dict
+)
+
+var kindNames = []string{"any", "integer", "string", "list", "dictionary"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// Schema describes the expected structure of a bencoded value.
+type Schema struct {
+	Kind Kind
+
+	// Optional allows the value to be absent from its dictionary.
+	Optional bool
+
+	// Elem describes the elements of a List.  If nil elements may be
+	// anything.
+	Elem *Schema
+
+	// Keys describes the known keys of a Dict.  A nil Schema requires a
+	// key without restricting its value.  Keys not listed are allowed
+	// unless Strict is true.
+	Keys   map[string]*Schema
+	Strict bool
+}
+
+// Validate checks that p is a single bencoded value with the structure
+// described by s, without decoding it.  The error identifies the first
+// offending value by its path, as used by Get.
+func Validate(p []byte, s *Schema) error {
+	dec := NewDecoderBytes(p)
+	err := dec.validate(s, "")
+	if err != nil {
+		return err
+	}
+	if dec.pos < len(p) {
+		return fmt.Errorf("trailing bytes")
+	}
+	return nil
+}
+
+// validate checks the next value in the stream, found at path, against s.
+func (dec *Decoder) validate(s *Schema, path string) error {
+	if dec.pos >= len(dec.stream) {
+		return fmt.Errorf("%sunexpected end of input", pathPrefix(path))
+	}
+	kind := Any
+	switch c := dec.stream[dec.pos]; {
+	case c == 'i':
+		kind = Integer
+	case c >= '0' && c <= '9':
+		kind = String
+	case c == 'l':
+		kind = List
+	case c == 'd':
+		kind = Dict
+	}
+	if s == nil || s.Kind == Any || kind == Any {
+		err := dec.skip()
+		if err != nil {
+			return fmt.Errorf("%s%v", pathPrefix(path), err)
+		}
+		return nil
+	}
+	if kind != s.Kind {
+		return fmt.Errorf("%sexpected %v, found %v", pathPrefix(path), s.Kind, kind)
+	}
+	var err error
+	switch kind {
+	case List:
+		dec.pos++ //skip 'l'
+		for i := 0; err == nil; i++ {
+			if dec.pos < len(dec.stream) && dec.stream[dec.pos] == 'e' {
+				dec.pos++ //skip 'e'
+				return nil
+			}
+			err = dec.validate(s.Elem, join(path, fmt.Sprint(i)))
+		}
+		return err
+	case Dict:
+		dec.pos++ //skip 'd'
+		seen := make(map[string]bool, len(s.Keys))
+		for {
+			if dec.pos >= len(dec.stream) {
+				return fmt.Errorf("%sunterminated dictionary", pathPrefix(path))
+			}
+			if dec.stream[dec.pos] == 'e' {
+				dec.pos++ //skip 'e'
+				break
+			}
+			k, err := dec.scanString()
+			if err != nil {
+				return fmt.Errorf("%s%v", pathPrefix(path), err)
+			}
+			key := string(k)
+			ks, ok := s.Keys[key]
+			if !ok && s.Strict {
+				return fmt.Errorf("%sunexpected key %q", pathPrefix(path), key)
+			}
+			seen[key] = true
+			err = dec.validate(ks, join(path, key))
+			if err != nil {
+				return err
+			}
+		}
+		var missing []string
+		for key, ks := range s.Keys {
+			if !seen[key] && (ks == nil || !ks.Optional) {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return fmt.Errorf("%smissing key %q", pathPrefix(path), missing[0])
+		}
+		return nil
+	case Integer:
+		_, _, err = dec.scanInteger()
+	default:
+		_, err = dec.scanString()
+	}
+	if err != nil {
+		return fmt.Errorf("%s%v", pathPrefix(path), err)
+	}
+	return nil
+}
+
+// pathPrefix returns a prefix identifying path in an error message.
+func pathPrefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
@@ -0,0 +1,57 @@
+package bencoding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	file := &Schema{Kind: Dict, Keys: map[string]*Schema{
+		"length": {Kind: Integer},
+		"path":   {Kind: List, Elem: &Schema{Kind: String}},
+	}}
+	schema := &Schema{Kind: Dict, Keys: map[string]*Schema{
+		"announce": {Kind: String, Optional: true},
+		"info": {Kind: Dict, Keys: map[string]*Schema{
+			"name":  {Kind: String},
+			"files": {Kind: List, Elem: file},
+		}},
+	}}
+	strict := &Schema{Kind: Dict, Strict: true, Keys: map[string]*Schema{
+		"a": nil,
+		"b": {Kind: Integer, Optional: true},
+	}}
+	for i, test := range []struct {
+		in     string
+		schema *Schema
+		err    string
+	}{
+		{"d4:infod5:filesld6:lengthi1e4:pathl1:aeee4:name1:xee", schema, ""},
+		{"d8:announce1:u4:infod5:filesle4:name1:x1:zi0eee", schema, ""},
+		{"d8:announcei1e4:infod5:filesle4:name1:xee", schema, "announce: expected string, found integer"},
+		{"d4:infod5:filesle4:namei1eee", schema, "info.name: expected string, found integer"},
+		{"d4:infod5:filesld6:lengthi1e4:pathli1eeeee4:name1:xee", schema, "info.files.0.path.0: expected string"},
+		{"d4:infod5:filesld4:pathleee4:name1:xee", schema, `info.files.0: missing key "length"`},
+		{"d4:infod4:name1:xee", schema, `info: missing key "files"`},
+		{"d4:infod5:filesle4:name1:xe", schema, "unterminated dictionary"},
+		{"d4:infod5:filesle4:name1:xeei1e", schema, "trailing bytes"},
+		{"le", schema, "expected dictionary, found list"},
+		{"d1:ali1eee", strict, ""},
+		{"d1:a0:1:bi1ee", strict, ""},
+		{"d1:bi1ee", strict, `missing key "a"`},
+		{"d1:a0:1:c0:e", strict, `unexpected key "c"`},
+		{"i01e", nil, "leading zero"},
+		{"i1e", nil, ""},
+	} {
+		err := Validate([]byte(test.in), test.schema)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("test %d: %v", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("test %d: error %v (expected %q)", i, err, test.err)
+		}
+	}
+}
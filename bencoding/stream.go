@@ -0,0 +1,98 @@
+package bencoding
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// readDocument reads one complete bencoded value from r and appends it to
+// buf.  Only the structure of the value is checked; it is validated when
+// decoded.  EOF is returned if r is consumed before the value begins.
+func readDocument(r *bufio.Reader, buf []byte) ([]byte, error) {
+	depth := 0
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF && len(buf) == 0 {
+			return nil, EOF
+		}
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, c)
+		switch {
+		case c == 'i':
+			buf, err = readThrough(r, buf, 'e')
+		case c == 'l' || c == 'd':
+			depth++
+		case c == 'e' && depth > 0:
+			depth--
+		case c >= '0' && c <= '9':
+			buf, err = readString(r, buf)
+		default:
+			err = fmt.Errorf("unexpected byte %x", c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if depth == 0 {
+			return buf, nil
+		}
+	}
+}
+
+// readThrough appends bytes from r to buf through the delimiter delim.  At
+// most maxDigits+2 bytes are read, enough for any valid integer or string
+// length.
+func readThrough(r *bufio.Reader, buf []byte, delim byte) ([]byte, error) {
+	for i := 0; i < maxDigits+2; i++ {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, c)
+		if c == delim {
+			return buf, nil
+		}
+	}
+	return nil, fmt.Errorf("number too long")
+}
+
+// readString appends the rest of a string, whose first length digit is the
+// last byte of buf, from r to buf.
+func readString(r *bufio.Reader, buf []byte) ([]byte, error) {
+	start := len(buf) - 1
+	buf, err := readThrough(r, buf, ':')
+	if err != nil {
+		return nil, err
+	}
+	n, ok := parseMagnitude(buf[start : len(buf)-1])
+	if !ok {
+		return nil, fmt.Errorf("string too long")
+	}
+	// read in chunks so a bogus length cannot force a huge allocation.
+	const chunk = 32 << 10
+	for n > 0 {
+		m := n
+		if m > chunk {
+			m = chunk
+		}
+		off := len(buf)
+		buf = append(buf, make([]byte, m)...)
+		_, err = io.ReadFull(r, buf[off:])
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		n -= m
+	}
+	return buf, nil
+}
@@ -0,0 +1,110 @@
+package bencoding
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewDecoder(t *testing.T) {
+	in := "d1:ai1e1:bl3:xyzee" + "i-5e" + "11:hello world" + "le"
+	expect := []interface{}{
+		map[string]interface{}{"a": int64(1), "b": []interface{}{"xyz"}},
+		int64(-5),
+		"hello world",
+		[]interface{}(nil),
+	}
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader(in)))
+	for i := range expect {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err != nil {
+			t.Fatalf("document %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(v, expect[i]) {
+			t.Errorf("document %d: decoded %#v (expected %#v)", i, v, expect[i])
+		}
+	}
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != EOF {
+		t.Errorf("decoded past the end of input: %v", err)
+	}
+}
+
+func TestDecoder_ResetReader(t *testing.T) {
+	dec := NewDecoderBytes([]byte("i1e"))
+	var got []interface{}
+	for _, in := range []string{"d1:ai1ee" + "1:x", "l1:be", "3:xyz"} {
+		dec.ResetReader(strings.NewReader(in))
+		var v interface{}
+		err := dec.Decode(&v)
+		if err != nil {
+			t.Fatalf("%q: %v", in, err)
+		}
+		got = append(got, v)
+	}
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != EOF {
+		t.Errorf("decoded past the end of input: %v", err)
+	}
+	expect := []interface{}{
+		map[string]interface{}{"a": int64(1)},
+		[]interface{}{"b"},
+		"xyz",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("decoded %#v (expected %#v)", got, expect)
+	}
+}
+
+func TestNewDecoder_errors(t *testing.T) {
+	for _, in := range []string{
+		"d1:ai1e",
+		"5:abc",
+		"i12",
+		"x",
+		"i123456789012345678901234567890e",
+	} {
+		dec := NewDecoder(strings.NewReader(in))
+		var err error
+		for err == nil {
+			var v interface{}
+			err = dec.Decode(&v)
+		}
+		if err == EOF {
+			t.Errorf("%q: no error", in)
+		}
+	}
+}
+
+func TestDecoder_Buffered(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("d1:ai1ee" + "raw data"))
+	var v map[string]int
+	err := dec.Decode(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ioutil.ReadAll(dec.Buffered())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != "raw data" {
+		t.Errorf("buffered %q", p)
+	}
+}
+
+func TestNewDecoder_Visit(t *testing.T) {
+	dec := NewDecoder(iotest.HalfReader(strings.NewReader("li1e1:ae")))
+	v := new(tokenVisitor)
+	err := dec.Visit(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens := strings.Join(v.tokens, " "); tokens != `[ 1 "a" ]` {
+		t.Errorf("visited %s", tokens)
+	}
+}
@@ -0,0 +1,237 @@
+package bencoding
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RewriteFunc computes the new encoding of a value rewritten by a
+// Transcoder.  Raw is the value's current encoding, a slice of the
+// decoder's input, or nil if its dictionary has no such key.  Returning a
+// nil encoding drops the key, or does not insert it if it was absent.
+type RewriteFunc func(raw []byte) ([]byte, error)
+
+// A Transcoder copies bencoded documents to an output while rewriting the
+// values at selected paths.  Values which are not on the path to a rewritten
+// value are copied without being decoded, so their bytes are preserved
+// exactly.  Paths are sequences of dictionary keys and list indices
+// separated by dots, as for Get.
+type Transcoder struct {
+	rules    map[string]RewriteFunc
+	children map[string][]string // sorted keys of rules, by parent path
+	prefixes map[string]bool     // paths containing rules
+	buf      []byte
+}
+
+// NewTranscoder allocates and returns a Transcoder without any rules.
+func NewTranscoder() *Transcoder {
+	return &Transcoder{
+		rules:    make(map[string]RewriteFunc),
+		children: make(map[string][]string),
+		prefixes: make(map[string]bool),
+	}
+}
+
+// Rewrite causes fn to compute the value at path.  If the last element of
+// path is a dictionary key which is absent, fn is called with nil raw and
+// the key is inserted at its sorted position if fn returns an encoding.
+func (t *Transcoder) Rewrite(path string, fn RewriteFunc) {
+	if _, ok := t.rules[path]; !ok {
+		parent, key := "", path
+		if i := strings.LastIndex(path, "."); i >= 0 {
+			parent, key = path[:i], path[i+1:]
+		}
+		keys := append(t.children[parent], key)
+		sort.Strings(keys)
+		t.children[parent] = keys
+		for p := parent; ; p = p[:strings.LastIndex(p, ".")] {
+			t.prefixes[p] = true
+			if !strings.Contains(p, ".") {
+				t.prefixes[""] = true
+				break
+			}
+		}
+	}
+	t.rules[path] = fn
+}
+
+// Drop causes the value at path to be removed.
+func (t *Transcoder) Drop(path string) {
+	t.Rewrite(path, func([]byte) ([]byte, error) { return nil, nil })
+}
+
+// Set causes the value at path to be replaced with, or inserted as, the
+// encoding of v.
+func (t *Transcoder) Set(path string, v interface{}) error {
+	p, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.Rewrite(path, func([]byte) ([]byte, error) { return p, nil })
+	return nil
+}
+
+// Transcode reads the next document from dec and writes its rewritten
+// encoding to w.  EOF is returned when dec's input is consumed.
+func (t *Transcoder) Transcode(w io.Writer, dec *Decoder) error {
+	err := dec.more()
+	if err != nil {
+		return err
+	}
+	p, err := t.transcode(t.buf[:0], dec, "")
+	if err != nil {
+		return err
+	}
+	t.buf = p
+	_, err = w.Write(p)
+	return err
+}
+
+// transcode appends the rewritten encoding of the next value in dec, found
+// at path, to dst.
+func (t *Transcoder) transcode(dst []byte, dec *Decoder, path string) ([]byte, error) {
+	start := dec.pos
+	if dec.pos >= len(dec.stream) || !t.prefixes[path] {
+		err := dec.skip()
+		if err == EOF {
+			err = fmt.Errorf("unexpected end of input")
+		}
+		return append(dst, dec.stream[start:dec.pos]...), err
+	}
+	switch dec.stream[dec.pos] {
+	case 'l':
+		dec.pos++ //skip 'l'
+		dst = append(dst, 'l')
+		for i := 0; ; i++ {
+			if dec.pos >= len(dec.stream) {
+				return nil, fmt.Errorf("unterminated list")
+			}
+			if dec.stream[dec.pos] == 'e' {
+				dec.pos++ //skip 'e'
+				return append(dst, 'e'), nil
+			}
+			var err error
+			dst, err = t.transcodeElem(dst, dec, path, strconv.Itoa(i), nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case 'd':
+		dec.pos++ //skip 'd'
+		dst = append(dst, 'd')
+		pending := t.children[path]
+		for {
+			if dec.pos >= len(dec.stream) {
+				return nil, fmt.Errorf("unterminated dictionary")
+			}
+			var k []byte
+			if dec.stream[dec.pos] != 'e' {
+				var err error
+				k, err = dec.scanString()
+				if err != nil {
+					return nil, err
+				}
+			}
+			// insert absent keys which sort before k.
+			for len(pending) > 0 && (k == nil || pending[0] < string(k)) {
+				var err error
+				dst, err = t.insert(dst, join(path, pending[0]), pending[0])
+				if err != nil {
+					return nil, err
+				}
+				pending = pending[1:]
+			}
+			if k == nil {
+				dec.pos++ //skip 'e'
+				return append(dst, 'e'), nil
+			}
+			if len(pending) > 0 && pending[0] == string(k) {
+				pending = pending[1:]
+			}
+			var err error
+			dst, err = t.transcodeElem(dst, dec, path, string(k), k)
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		err := dec.skip()
+		return append(dst, dec.stream[start:dec.pos]...), err
+	}
+}
+
+// transcodeElem appends the rewritten encoding of the list element or
+// dictionary entry with the given path element.  Key is nil for list
+// elements.
+func (t *Transcoder) transcodeElem(dst []byte, dec *Decoder, path, elem string, key []byte) ([]byte, error) {
+	path = join(path, elem)
+	fn, ok := t.rules[path]
+	if !ok {
+		if key != nil {
+			dst = appendBytes(dst, key)
+		}
+		return t.transcode(dst, dec, path)
+	}
+	start := dec.pos
+	err := dec.skip()
+	if err == EOF {
+		err = fmt.Errorf("unexpected end of input")
+	}
+	if err != nil {
+		return nil, err
+	}
+	p, err := rewrite(fn, path, dec.stream[start:dec.pos])
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		if key == nil {
+			return nil, fmt.Errorf("%s: cannot drop a list element", path)
+		}
+		return dst, nil
+	}
+	if key != nil {
+		dst = appendBytes(dst, key)
+	}
+	return append(dst, p...), nil
+}
+
+// insert appends the entry for an absent key if its rule provides a value.
+func (t *Transcoder) insert(dst []byte, path, key string) ([]byte, error) {
+	p, err := rewrite(t.rules[path], path, nil)
+	if err != nil || p == nil {
+		return dst, err
+	}
+	dst = appendString(dst, key)
+	return append(dst, p...), nil
+}
+
+// rewrite calls fn and checks that the result is a single bencoded value.
+func rewrite(fn RewriteFunc, path string, raw []byte) ([]byte, error) {
+	p, err := fn(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if p == nil {
+		return nil, nil
+	}
+	dec := NewDecoderBytes(p)
+	err = dec.skip()
+	if err == nil && dec.pos < len(p) {
+		err = fmt.Errorf("trailing bytes")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid encoding: %v", path, err)
+	}
+	return p, nil
+}
+
+func join(path, elem string) string {
+	if path == "" {
+		return elem
+	}
+	return path + "." + elem
+}
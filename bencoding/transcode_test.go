@@ -0,0 +1,105 @@
+package bencoding
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestTranscoder(t *testing.T) {
+	in := "d8:announce3:url7:comment1:c4:infod6:lengthi1e4:name1:xe5:nodesld1:ai1eeee"
+	for i, test := range []struct {
+		rules func(*Transcoder) error
+		out   string
+	}{
+		{
+			func(t *Transcoder) error { return nil },
+			in,
+		},
+		{
+			func(t *Transcoder) error {
+				t.Drop("announce")
+				return t.Set("info.source", "src")
+			},
+			"d7:comment1:c4:infod6:lengthi1e4:name1:x6:source3:srce5:nodesld1:ai1eeee",
+		},
+		{
+			func(t *Transcoder) error {
+				t.Drop("missing")
+				t.Drop("info.private")
+				return t.Set("info.a", 1)
+			},
+			"d8:announce3:url7:comment1:c4:infod1:ai1e6:lengthi1e4:name1:xe5:nodesld1:ai1eeee",
+		},
+		{
+			func(t *Transcoder) error {
+				t.Rewrite("comment", func(raw []byte) ([]byte, error) {
+					return append([]byte("1:"), raw[len(raw)-1]), nil
+				})
+				t.Drop("nodes.0.a")
+				return t.Set("zz", []string{})
+			},
+			"d8:announce3:url7:comment1:c4:infod6:lengthi1e4:name1:xe5:nodesldee2:zzlee",
+		},
+	} {
+		tc := NewTranscoder()
+		err := test.rules(tc)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		var buf bytes.Buffer
+		err = tc.Transcode(&buf, NewDecoderBytes([]byte(in)))
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if buf.String() != test.out {
+			t.Errorf("test %d: transcoded %q (expected %q)", i, buf.String(), test.out)
+		}
+	}
+}
+
+func TestTranscoder_errors(t *testing.T) {
+	for i, test := range []struct {
+		in    string
+		rules func(*Transcoder)
+	}{
+		{"d1:ai1e", func(t *Transcoder) { t.Drop("b") }},
+		{"d1:ai1e", func(t *Transcoder) {}},
+		{"d1:ali1eee", func(t *Transcoder) { t.Drop("a.0") }},
+		{"d1:ai1ee", func(t *Transcoder) {
+			t.Rewrite("a", func([]byte) ([]byte, error) { return []byte("i1ei2e"), nil })
+		}},
+		{"d1:ai1ee", func(t *Transcoder) {
+			t.Rewrite("b", func([]byte) ([]byte, error) { return nil, fmt.Errorf("no") })
+		}},
+	} {
+		tc := NewTranscoder()
+		test.rules(tc)
+		var buf bytes.Buffer
+		err := tc.Transcode(&buf, NewDecoderBytes([]byte(test.in)))
+		if err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
+
+func TestTranscoder_stream(t *testing.T) {
+	tc := NewTranscoder()
+	tc.Drop("a")
+	dec := NewDecoderBytes([]byte("d1:ai1e1:bi2eei3ed1:ai4ee"))
+	var buf bytes.Buffer
+	for {
+		err := tc.Transcode(&buf, dec)
+		if err == EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.String() != "d1:bi2eei3ede" {
+		t.Errorf("transcoded %q", buf.String())
+	}
+}
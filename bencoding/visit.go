@@ -0,0 +1,107 @@
+package bencoding
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SkipValue may be returned by Visitor.Key to skip the value of the key.  No
+// callbacks are made for a skipped value, though it is still validated.
+var SkipValue = errors.New("skip this value")
+
+// A Visitor receives the elements of a document from Decoder.Visit in the
+// order they are encoded.  Integers are passed as Numbers so that integers of
+// any magnitude can be visited.  Byte slices passed to a Visitor are slices of
+// the decoder's input and are only valid during the call.  Returning a
+// non-nil error from any method stops the visit and Visit returns the error.
+type Visitor interface {
+	Integer(n Number) error
+	String(s []byte) error
+	BeginList() error
+	EndList() error
+	BeginDict() error
+	Key(k []byte) error
+	EndDict() error
+}
+
+// Visit reads the next document from the input stream and calls the methods
+// of v for each of its elements, without building any in-memory
+// representation of the document.  Hooks are not called by Visit.
+func (dec *Decoder) Visit(v Visitor) error {
+	err := dec.more()
+	if err != nil {
+		return err
+	}
+	dec.start = dec.pos
+	return dec.visit(v)
+}
+
+// visit calls v for the next value in the stream.
+func (dec *Decoder) visit(v Visitor) error {
+	if dec.pos >= len(dec.stream) {
+		return fmt.Errorf("unexpected end of input")
+	}
+	switch c := dec.stream[dec.pos]; {
+	case c == 'i':
+		neg, digits, err := dec.scanInteger()
+		if err != nil {
+			return err
+		}
+		return v.Integer(number(neg, digits))
+	case c >= '0' && c <= '9':
+		s, err := dec.scanString()
+		if err != nil {
+			return err
+		}
+		return v.String(s)
+	case c == 'l':
+		dec.pos++ //skip 'l'
+		err := v.BeginList()
+		if err != nil {
+			return err
+		}
+		for {
+			if dec.pos >= len(dec.stream) {
+				return fmt.Errorf("unterminated list")
+			}
+			if dec.stream[dec.pos] == 'e' {
+				dec.pos++ //skip 'e'
+				return v.EndList()
+			}
+			err = dec.visit(v)
+			if err != nil {
+				return err
+			}
+		}
+	case c == 'd':
+		dec.pos++ //skip 'd'
+		err := v.BeginDict()
+		if err != nil {
+			return err
+		}
+		for {
+			if dec.pos >= len(dec.stream) {
+				return fmt.Errorf("unterminated dictionary")
+			}
+			if dec.stream[dec.pos] == 'e' {
+				dec.pos++ //skip 'e'
+				return v.EndDict()
+			}
+			k, err := dec.scanString()
+			if err != nil {
+				return err
+			}
+			err = v.Key(k)
+			if err == SkipValue {
+				err = dec.skip()
+			} else if err == nil {
+				err = dec.visit(v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unexpected byte %x at offset %d", c, dec.pos)
+	}
+}
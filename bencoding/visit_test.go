@@ -0,0 +1,90 @@
+package bencoding
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// tokenVisitor records the elements it visits as a space separated string.
+type tokenVisitor struct {
+	tokens []string
+	skip   string
+}
+
+func (v *tokenVisitor) add(format string, args ...interface{}) error {
+	v.tokens = append(v.tokens, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (v *tokenVisitor) Integer(n Number) error { return v.add("%s", n) }
+func (v *tokenVisitor) String(s []byte) error  { return v.add("%q", s) }
+func (v *tokenVisitor) BeginList() error       { return v.add("[") }
+func (v *tokenVisitor) EndList() error         { return v.add("]") }
+func (v *tokenVisitor) BeginDict() error       { return v.add("{") }
+func (v *tokenVisitor) EndDict() error         { return v.add("}") }
+
+func (v *tokenVisitor) Key(k []byte) error {
+	if string(k) == v.skip {
+		return SkipValue
+	}
+	return v.add("%s:", k)
+}
+
+func TestDecoder_Visit(t *testing.T) {
+	for i, test := range []struct {
+		in     string
+		skip   string
+		tokens string
+		err    bool
+	}{
+		{"i-12e", "", "-12", false},
+		{"i-9223372036854775808e", "", "-9223372036854775808", false},
+		{"3:abc", "", `"abc"`, false},
+		{"le", "", "[ ]", false},
+		{"li1e1:ae", "", `[ 1 "a" ]`, false},
+		{"d1:ad1:bli1eee1:c0:e", "", `{ a: { b: [ 1 ] } c: "" }`, false},
+		{"d1:ad1:bli1eee1:c0:e", "a", `{ c: "" }`, false},
+		{"i9223372036854775808e", "", "9223372036854775808", false},
+		{"li-123456789012345678901234567890ee", "", "[ -123456789012345678901234567890 ]", false},
+		{"i01e", "", "", true},
+		{"d1:ali1e", "", "", true},
+		{"d1:ad1:bli1e", "a", "", true},
+		{"d1:ai1exe", "", "", true},
+	} {
+		v := &tokenVisitor{skip: test.skip}
+		err := NewDecoderBytes([]byte(test.in)).Visit(v)
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		tokens := strings.Join(v.tokens, " ")
+		if tokens != test.tokens {
+			t.Errorf("test %d: visited %s (expected %s)", i, tokens, test.tokens)
+		}
+	}
+}
+
+func TestDecoder_Visit_stream(t *testing.T) {
+	dec := NewDecoderBytes([]byte("i1e1:ale"))
+	v := new(tokenVisitor)
+	for {
+		err := dec.Visit(v)
+		if err == EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tokens := strings.Join(v.tokens, " ")
+	if tokens != `1 "a" [ ]` {
+		t.Errorf("visited %s", tokens)
+	}
+}
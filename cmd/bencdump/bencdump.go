@@ -0,0 +1,111 @@
+/*
+Command bencdump pretty-prints bencoded files such as torrents, resume data,
+and DHT state.
+
+	bencdump [-path path] [-json] [file ...]
+
+Standard input is read when no files are given.  The -path flag selects an
+element of each document with a dotted sequence of dictionary keys and list
+indices (e.g. info.files.0.path).
+
+With -json each document is printed as a JSON value.  Strings that are not
+valid UTF-8 are printed as {"hex": "<hex string>"}.  Dictionaries with keys
+that are not valid UTF-8, such as the info hashes of scrape responses, are
+printed as {"hex": {...}} with every key in hex.
+*/
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"unicode/utf8"
+
+	"github.com/bmatsuo/torrent/bencoding"
+)
+
+// jsonValue converts a decoded bencoding value to a value that encodes as
+// JSON without losing binary strings and keys.
+func jsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		if !utf8.ValidString(x) {
+			return hexValue{hex.EncodeToString([]byte(x))}
+		}
+	case []interface{}:
+		list := make([]interface{}, len(x))
+		for i := range x {
+			list[i] = jsonValue(x[i])
+		}
+		return list
+	case map[string]interface{}:
+		binary := false
+		for k := range x {
+			binary = binary || !utf8.ValidString(k)
+		}
+		m := make(map[string]interface{}, len(x))
+		for k, elem := range x {
+			if binary {
+				k = hex.EncodeToString([]byte(k))
+			}
+			m[k] = jsonValue(elem)
+		}
+		if binary {
+			return hexValue{m}
+		}
+		return m
+	}
+	return v
+}
+
+// hexValue marks a hex encoded string, or a dictionary with hex encoded keys.
+type hexValue struct {
+	Hex interface{} `json:"hex"`
+}
+
+func dump(filename string, p []byte, path string, jsonOutput bool) {
+	var v interface{}
+	err := bencoding.Unmarshal(p, &v)
+	if err != nil {
+		log.Fatalf("%s: %v", filename, err)
+	}
+	v, err = bencoding.Get(v, path)
+	if err != nil {
+		log.Fatalf("%s: %v", filename, err)
+	}
+	if jsonOutput {
+		err = json.NewEncoder(os.Stdout).Encode(jsonValue(v))
+	} else {
+		err = bencoding.Dump(os.Stdout, v)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	path := flag.String("path", "", "dotted path of the element to print (e.g. info.files.0.path)")
+	jsonOutput := flag.Bool("json", false, "print JSON")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("bencdump: ")
+
+	if flag.NArg() == 0 {
+		p, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dump("<stdin>", p, *path, *jsonOutput)
+		return
+	}
+	for _, filename := range flag.Args() {
+		p, err := ioutil.ReadFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dump(filename, p, *path, *jsonOutput)
+	}
+}
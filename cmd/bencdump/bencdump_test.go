@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONValue(t *testing.T) {
+	for _, test := range []struct {
+		v      interface{}
+		expect string
+	}{
+		{"abc", `"abc"`},
+		{"\xff\x00", `{"hex":"ff00"}`},
+		{[]interface{}{int64(1), "\xfe"}, `[1,{"hex":"fe"}]`},
+		{map[string]interface{}{"a": "b"}, `{"a":"b"}`},
+		{map[string]interface{}{"a": "b", "\xff": "\xfe"}, `{"hex":{"61":"b","ff":{"hex":"fe"}}}`},
+	} {
+		p, err := json.Marshal(jsonValue(test.v))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(p) != test.expect {
+			t.Errorf("%q: json %s (expected %s)", test.v, p, test.expect)
+		}
+	}
+}
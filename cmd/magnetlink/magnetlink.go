@@ -0,0 +1,174 @@
+/*
+Command magnetlink converts between torrent files and magnet links.
+
+	magnetlink [flags] <torrent|magnet> ...
+
+Given a torrent file magnetlink prints a magnet link for it.  Given a magnet
+link it writes the matching torrent file, which must be found in a local
+directory of torrent files named by -cache.  Fetching metadata from the DHT
+is not supported.
+
+Both btih (BitTorrent v1) and btmh (BitTorrent v2 multihash) links are
+recognized.
+*/
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/magnet"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// torrentFile is a torrent file with its info dictionary in its original
+// encoding.
+type torrentFile struct {
+	Announce     string               `bencoding:"announce,omitempty"`
+	AnnounceList [][]string           `bencoding:"announce-list,omitempty"`
+	URLList      metainfo.URLList     `bencoding:"url-list,omitempty"`
+	Info         bencoding.RawMessage `bencoding:"info"`
+}
+
+// infoFields are the info dictionary fields used in magnet links.
+type infoFields struct {
+	Name        string `bencoding:"name"`
+	MetaVersion int64  `bencoding:"meta version,omitempty"`
+}
+
+func readTorrent(filename string) ([]byte, *torrentFile, error) {
+	p, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	t := new(torrentFile)
+	err = bencoding.Unmarshal(p, t)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(t.Info) == 0 {
+		return nil, nil, fmt.Errorf("%s: missing info dictionary", filename)
+	}
+	return p, t, nil
+}
+
+// magnetLink returns a magnet link for t.  A btmh hash is included for
+// BitTorrent v2 torrents.
+func magnetLink(t *torrentFile) (string, error) {
+	var info infoFields
+	err := bencoding.Unmarshal(t.Info, &info)
+	if err != nil {
+		return "", err
+	}
+	v1 := sha1.Sum(t.Info)
+	m := &magnet.Link{InfoHash: v1[:], Name: info.Name, WebSeeds: t.URLList}
+	if info.MetaVersion == 2 {
+		v2 := sha256.Sum256(t.Info)
+		m.InfoHashV2 = v2[:]
+	}
+	for _, tier := range t.AnnounceList {
+		m.Trackers = append(m.Trackers, tier...)
+	}
+	if len(t.AnnounceList) == 0 && t.Announce != "" {
+		m.Trackers = append(m.Trackers, t.Announce)
+	}
+	return m.String(), nil
+}
+
+// matches returns true if the info dictionary info has a hash in m.
+func matches(m *magnet.Link, info []byte) bool {
+	if m.InfoHash != nil {
+		h := sha1.Sum(info)
+		if bytes.Equal(h[:], m.InfoHash) {
+			return true
+		}
+	}
+	if m.InfoHashV2 != nil {
+		h := sha256.Sum256(info)
+		if bytes.Equal(h[:], m.InfoHashV2) {
+			return true
+		}
+	}
+	return false
+}
+
+// findTorrent returns the contents of the first torrent file in dir that
+// matches m.
+func findTorrent(m *magnet.Link, dir string) ([]byte, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.torrent"))
+	if err != nil {
+		return nil, err
+	}
+	for _, filename := range filenames {
+		p, t, err := readTorrent(filename)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		if matches(m, t.Info) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("torrent not found in %s", dir)
+}
+
+func main() {
+	cache := flag.String("cache", "", "directory of torrent files used to resolve magnet links")
+	outpath := flag.String("o", "", "output torrent path for a magnet link (default: <name>.torrent)")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("magnetlink: ")
+	if flag.NArg() == 0 || *outpath != "" && flag.NArg() > 1 {
+		log.Fatalf("usage: %s [flags] <torrent|magnet> ...", os.Args[0])
+	}
+	for _, arg := range flag.Args() {
+		if !strings.HasPrefix(arg, "magnet:") {
+			_, t, err := readTorrent(arg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			link, err := magnetLink(t)
+			if err != nil {
+				log.Fatalf("%s: %v", arg, err)
+			}
+			fmt.Println(link)
+			continue
+		}
+
+		m, err := magnet.Parse(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *cache == "" {
+			log.Fatal("no metadata source for magnet link (use -cache)")
+		}
+		p, err := findTorrent(m, *cache)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out := *outpath
+		if out == "" {
+			name := m.Name
+			if name == "" && m.InfoHash != nil {
+				name = fmt.Sprintf("%x", m.InfoHash)
+			} else if name == "" {
+				name = fmt.Sprintf("%x", m.InfoHashV2)
+			}
+			out = filepath.Base(name) + ".torrent"
+		}
+		err = ioutil.WriteFile(out, p, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(out)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// hashCache records the file layout and piece hashes of a previously created
+// torrent so that pieces containing only unmodified data need not be hashed
+// again.  Piece boundaries are implied by the piece length and file lengths.
+// The md5sum of a single-file torrent is recorded because computing it
+// requires reading the whole file.
+type hashCache struct {
+	PieceLength int64        `bencoding:"piece length"`
+	Files       []cachedFile `bencoding:"files"`
+	Pieces      []byte       `bencoding:"pieces"`
+	MD5Sum      string       `bencoding:"md5sum,omitempty"`
+}
+
+// cachedFile identifies the content of one file in a hashCache.
+type cachedFile struct {
+	Path    []string `bencoding:"path"`
+	Length  int64    `bencoding:"length"`
+	ModTime int64    `bencoding:"mtime"` // unix nanoseconds
+}
+
+func (f cachedFile) equal(g cachedFile) bool {
+	if f.Length != g.Length || f.ModTime != g.ModTime || len(f.Path) != len(g.Path) {
+		return false
+	}
+	for i := range f.Path {
+		if f.Path[i] != g.Path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readCache reads a hashCache from filename.  An empty cache is returned if
+// filename does not exist.
+func readCache(filename string) (*hashCache, error) {
+	p, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return new(hashCache), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cache := new(hashCache)
+	err = bencoding.Unmarshal(p, cache)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func writeCache(filename string, cache *hashCache) error {
+	p, err := bencoding.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, p, 0644)
+}
+
+// cacheFiles returns the cache entries describing inputs.
+func cacheFiles(inputs []inputFile) []cachedFile {
+	files := make([]cachedFile, len(inputs))
+	for i, input := range inputs {
+		files[i] = cachedFile{input.meta, input.size, input.mtime}
+	}
+	return files
+}
+
+// sameFiles returns true if a and b describe the same unmodified files.
+func sameFiles(a, b []cachedFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedPiece returns the hash of piece i from cache if every file with data
+// in the piece is unmodified and at the same offset as when the cache was
+// written.
+func cachedPiece(cache *hashCache, files []cachedFile, plen int64, i int) []byte {
+	if cache.PieceLength != plen || len(cache.Pieces) < (i+1)*sha1.Size {
+		return nil
+	}
+	start, end := int64(i)*plen, int64(i+1)*plen
+	if !samePieceData(cache.Files, files, start, end) {
+		return nil
+	}
+	return cache.Pieces[i*sha1.Size : (i+1)*sha1.Size]
+}
+
+// samePieceData returns true if the files in a and b with data in the range
+// [start, end) are equal and have the same offsets, and neither layout ends
+// within the range unless both end at the same offset.
+func samePieceData(a, b []cachedFile, start, end int64) bool {
+	sa, enda := pieceFiles(a, start, end)
+	sb, endb := pieceFiles(b, start, end)
+	if enda != endb || len(sa) != len(sb) {
+		return false
+	}
+	for i := range sa {
+		if sa[i].offset != sb[i].offset || !sa[i].file.equal(sb[i].file) {
+			return false
+		}
+	}
+	return true
+}
+
+type placedFile struct {
+	file   cachedFile
+	offset int64
+}
+
+// pieceFiles returns the non-empty files with data in [start, end) and the
+// end of the range after truncation to the total length of files.
+func pieceFiles(files []cachedFile, start, end int64) ([]placedFile, int64) {
+	var placed []placedFile
+	var offset int64
+	for _, f := range files {
+		if f.Length > 0 && offset < end && offset+f.Length > start {
+			placed = append(placed, placedFile{f, offset})
+		}
+		offset += f.Length
+	}
+	if offset < end {
+		end = offset
+	}
+	return placed, end
+}
+
+// createCached creates a torrent from inputs, hashing only pieces which are
+// not found in cache.  The cache is updated to describe the new torrent.  A
+// single-file torrent has the md5sum a metainfo.Writer computes, so its whole
+// file is read unless the file is unchanged since the cache was written.
+func createCached(name string, single bool, inputs []inputFile, plen int64, flags infoFlags, announce string, cache *hashCache, prog *progress) (*metainfo.Metainfo, error) {
+	files := cacheFiles(inputs)
+	var total int64
+	for _, input := range inputs {
+		total += input.size
+	}
+	npieces := int((total + plen - 1) / plen)
+	if npieces == 0 {
+		npieces = 1
+	}
+	md5sum := cache.MD5Sum
+	needMD5 := single && (md5sum == "" || !sameFiles(cache.Files, files))
+	pieces := make([]byte, 0, npieces*sha1.Size)
+	r := &inputReader{inputs: inputs, prog: prog}
+	defer r.Close()
+	buf := make([]byte, plen)
+	h := sha1.New()
+	m := md5.New()
+	for i := 0; i < npieces; i++ {
+		size := plen
+		if remain := total - int64(i)*plen; remain < size {
+			size = remain
+		}
+		hash := cachedPiece(cache, files, plen, i)
+		if hash != nil && !needMD5 {
+			prog.Skip(size)
+			pieces = append(pieces, hash...)
+			continue
+		}
+		n, err := r.ReadAt(buf[:size], int64(i)*plen)
+		if err != nil {
+			return nil, err
+		}
+		prog.Write(buf[:n])
+		if needMD5 {
+			m.Write(buf[:n])
+		}
+		if hash != nil {
+			pieces = append(pieces, hash...)
+			continue
+		}
+		h.Reset()
+		h.Write(buf[:n])
+		pieces = h.Sum(pieces)
+	}
+	if needMD5 {
+		md5sum = fmt.Sprintf("%x", m.Sum(nil))
+	}
+	if !single {
+		md5sum = ""
+	}
+
+	cache.PieceLength = plen
+	cache.Files = files
+	cache.Pieces = pieces
+	cache.MD5Sum = md5sum
+
+	info := metainfo.Info{
+		Name:        name,
+		PieceLength: plen,
+		Pieces:      pieces,
+	}
+	flags.set(&info)
+	if single {
+		info.Length = total
+		info.MD5Sum = md5sum
+	} else {
+		for _, input := range inputs {
+			file := metainfo.FileInfo{Path: input.meta, Length: input.size}
+			if input.link != nil {
+				file.Attr = "l"
+				file.SymlinkPath = input.link
+			}
+			info.Files = append(info.Files, file)
+		}
+	}
+	return &metainfo.Metainfo{Info: info, Announce: announce}, nil
+}
+
+// inputReader reads the concatenated content of input files.  Reads are
+// expected at increasing offsets so that only one file is open at a time.
+type inputReader struct {
+	inputs []inputFile
+	i      int   // index of the current input
+	offset int64 // offset of the current input
+	f      *os.File
+	prog   *progress // notified as each file is opened
+}
+
+// ReadAt reads len(p) bytes at offset off.  ReadAt returns io.EOF if fewer
+// than len(p) bytes remain.
+func (r *inputReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < r.offset {
+		r.Close()
+		r.i, r.offset = 0, 0
+	}
+	var n int
+	for n < len(p) {
+		if r.i >= len(r.inputs) {
+			return n, io.EOF
+		}
+		input := r.inputs[r.i]
+		if off+int64(n) >= r.offset+input.size {
+			r.Close()
+			r.i++
+			r.offset += input.size
+			continue
+		}
+		if r.f == nil {
+			f, err := os.Open(input.path)
+			if err != nil {
+				return n, err
+			}
+			r.f = f
+			r.prog.SetFile(input.path)
+		}
+		want := len(p) - n
+		if remain := r.offset + input.size - off - int64(n); int64(want) > remain {
+			want = int(remain)
+		}
+		_n, err := r.f.ReadAt(p[n:n+want], off+int64(n)-r.offset)
+		n += _n
+		if err == io.EOF {
+			return n, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (r *inputReader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
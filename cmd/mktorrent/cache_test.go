@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+const testPieceLength = 64
+
+// writeTestFile writes n pseudo-random bytes to path.
+func writeTestFile(t *testing.T, path string, n int, seed int64) {
+	p := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(p)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, p, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testInputs gathers the inputs for path as mktorrent does.
+func testInputs(t *testing.T, path string) []inputFile {
+	inputs, _, err := walkInputs([]string{path}, nil, followSymlinks, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Stable(inputsByPath(inputs))
+	return inputs
+}
+
+// uncachedHash returns the info hash of the torrent for path built without
+// a cache.
+func uncachedHash(t *testing.T, path string, single bool) metainfo.InfoHash {
+	meta, err := createTorrent(filepath.Base(path), single, testInputs(t, path), testPieceLength, 0, 1, infoFlags{}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := meta.Info.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// cachedHash returns the info hash of the torrent for path built with
+// cache.
+func cachedHash(t *testing.T, path string, single bool, cache *hashCache) metainfo.InfoHash {
+	meta, err := createCached(filepath.Base(path), single, testInputs(t, path), testPieceLength, infoFlags{}, "", cache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := meta.Info.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestCreateCached(t *testing.T) {
+	dir := t.TempDir()
+	single := filepath.Join(dir, "single")
+	writeTestFile(t, single, 3*testPieceLength+5, 1)
+	multi := filepath.Join(dir, "multi")
+	writeTestFile(t, filepath.Join(multi, "a"), testPieceLength+7, 2)
+	writeTestFile(t, filepath.Join(multi, "b", "c"), 0, 3)
+	writeTestFile(t, filepath.Join(multi, "b", "d"), 2*testPieceLength, 4)
+	empty := filepath.Join(dir, "empty")
+	writeTestFile(t, empty, 0, 5)
+
+	for _, test := range []struct {
+		path   string
+		single bool
+		change string // file modified before a third build
+	}{
+		{single, true, single},
+		{multi, false, filepath.Join(multi, "b", "d")},
+		{empty, true, ""},
+	} {
+		cache := new(hashCache)
+		expect := uncachedHash(t, test.path, test.single)
+		if h := cachedHash(t, test.path, test.single, cache); h != expect {
+			t.Errorf("%s: cold cache hash %v (!= %v)", test.path, h, expect)
+		}
+		if h := cachedHash(t, test.path, test.single, cache); h != expect {
+			t.Errorf("%s: warm cache hash %v (!= %v)", test.path, h, expect)
+		}
+		if test.change == "" {
+			continue
+		}
+		writeTestFile(t, test.change, 2*testPieceLength+1, 6)
+		expect = uncachedHash(t, test.path, test.single)
+		if h := cachedHash(t, test.path, test.single, cache); h != expect {
+			t.Errorf("%s: modified hash %v (!= %v)", test.path, h, expect)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// config holds default option values read from a configuration file and the
+// environment.  Flags given on the command line take precedence over both.
+//
+// The configuration file uses a small subset of TOML.  Each line is a
+// "key = value" pair where value is a quoted string, an integer, or an
+// array of quoted strings on a single line.
+//
+//	announce = ["http://tracker.example.com/announce", "udp://tracker.example.com:80"]
+//	source = "EXAMPLE"
+//	piece_length = 20  # log2 of the piece length, as for -l
+//	created_by = "me"
+//
+// The environment variables MKTORRENT_ANNOUNCE (comma separated),
+// MKTORRENT_SOURCE, MKTORRENT_PIECE_LENGTH, and MKTORRENT_CREATED_BY
+// override values from the configuration file.
+type config struct {
+	Announce    []string
+	Source      string
+	PieceLength int
+	CreatedBy   string
+}
+
+// defaultConfigPath returns the location of the user's configuration file.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "mktorrent.toml")
+}
+
+// readConfig reads the configuration file filename into c.
+func readConfig(filename string, c *config) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		err := c.setLine(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// stripComment removes a trailing comment from line, ignoring '#' characters
+// within quoted strings.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++ // skip the escaped character
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func (c *config) setLine(line string) error {
+	i := strings.Index(line, "=")
+	if i < 0 {
+		return fmt.Errorf("expected key = value")
+	}
+	key := strings.TrimSpace(line[:i])
+	val := strings.TrimSpace(line[i+1:])
+	var err error
+	switch key {
+	case "announce":
+		if strings.HasPrefix(val, "[") {
+			c.Announce, err = parseStrings(val)
+		} else {
+			var s string
+			s, err = parseString(val)
+			c.Announce = []string{s}
+		}
+	case "source":
+		c.Source, err = parseString(val)
+	case "piece_length":
+		c.PieceLength, err = strconv.Atoi(val)
+	case "created_by":
+		c.CreatedBy, err = parseString(val)
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	return nil
+}
+
+// parseString parses a basic (double quoted) or literal (single quoted) TOML
+// string.
+func parseString(val string) (string, error) {
+	if len(val) >= 2 && val[0] == '\'' && val[len(val)-1] == '\'' {
+		return val[1 : len(val)-1], nil
+	}
+	if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
+		return strconv.Unquote(val)
+	}
+	return "", fmt.Errorf("expected a quoted string")
+}
+
+// parseStrings parses a single-line TOML array of strings.
+func parseStrings(val string) ([]string, error) {
+	if !strings.HasPrefix(val, "[") || !strings.HasSuffix(val, "]") {
+		return nil, fmt.Errorf("expected an array")
+	}
+	val = strings.TrimSpace(val[1 : len(val)-1])
+	var ss []string
+	for val != "" {
+		if val[0] != '"' && val[0] != '\'' {
+			return nil, fmt.Errorf("expected a quoted string")
+		}
+		end := 1
+		for end < len(val) && val[end] != val[0] {
+			if val[0] == '"' && val[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(val) {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		s, err := parseString(val[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+		val = strings.TrimSpace(val[end+1:])
+		if strings.HasPrefix(val, ",") {
+			val = strings.TrimSpace(val[1:])
+		} else if val != "" {
+			return nil, fmt.Errorf("expected ','")
+		}
+	}
+	return ss, nil
+}
+
+// readEnv reads configuration from environment variables into c.
+func (c *config) readEnv() error {
+	if v := os.Getenv("MKTORRENT_ANNOUNCE"); v != "" {
+		c.Announce = strings.Split(v, ",")
+	}
+	if v := os.Getenv("MKTORRENT_SOURCE"); v != "" {
+		c.Source = v
+	}
+	if v := os.Getenv("MKTORRENT_PIECE_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MKTORRENT_PIECE_LENGTH: %v", err)
+		}
+		c.PieceLength = n
+	}
+	if v := os.Getenv("MKTORRENT_CREATED_BY"); v != "" {
+		c.CreatedBy = v
+	}
+	return nil
+}
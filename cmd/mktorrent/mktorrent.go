@@ -2,114 +2,824 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bmatsuo/torrent/bencoding"
 	"github.com/bmatsuo/torrent/metainfo"
 )
 
+// Exit codes distinguish the broad class of a failure.
+const (
+	exitUsage    = 2 // invalid flags, arguments, or configuration
+	exitIO       = 3 // reading input or writing output failed
+	exitEncoding = 4 // the torrent could not be created or encoded
+)
+
+// fatalf logs a formatted error message and exits with the given code.
+func fatalf(code int, format string, v ...interface{}) {
+	log.Printf(format, v...)
+	os.Exit(code)
+}
+
+// stringsFlag is a flag.Value that accumulates repeated flag values.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// readPatterns reads exclusion patterns from filename, one per line.  Blank
+// lines and lines beginning with '#' are ignored.
+func readPatterns(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// excluded returns true if the base name of path or its slash-separated path
+// relative to the input root rel matches any of patterns.
+func excluded(patterns []string, rel string) (bool, error) {
+	rel = filepath.ToSlash(rel)
+	base := filepath.Base(rel)
+	for _, pattern := range patterns {
+		ok, err := filepath.Match(pattern, base)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			ok, _ = filepath.Match(pattern, rel)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// inputFile is a file to be added to the torrent.
+type inputFile struct {
+	path  string
+	meta  []string
+	size  int64
+	mtime int64    // modification time in unix nanoseconds
+	link  []string // symlink target, for recorded symlinks
+}
+
+// inputsByPath sorts files lexicographically by their metainfo path
+// components so that torrent content is ordered independently of the
+// platform and file system.
+type inputsByPath []inputFile
+
+func (fs inputsByPath) Len() int      { return len(fs) }
+func (fs inputsByPath) Swap(i, j int) { fs[i], fs[j] = fs[j], fs[i] }
+func (fs inputsByPath) Less(i, j int) bool {
+	a, b := fs[i].meta, fs[j].meta
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// alignedFirst orders files that will be aligned to piece boundaries before
+// smaller files, which then share pieces without padding between them.
+type alignedFirst struct {
+	inputs []inputFile
+	align  int64
+}
+
+func (fs alignedFirst) Len() int      { return len(fs.inputs) }
+func (fs alignedFirst) Swap(i, j int) { fs.inputs[i], fs.inputs[j] = fs.inputs[j], fs.inputs[i] }
+func (fs alignedFirst) Less(i, j int) bool {
+	return aligned(fs.inputs[i], fs.align) && !aligned(fs.inputs[j], fs.align)
+}
+
+// aligned returns true if input must start at a piece boundary.
+func aligned(input inputFile, align int64) bool {
+	return align > 0 && input.link == nil && input.size >= align
+}
+
+// contentLength returns the length of the torrent content for inputs,
+// including the padding added to start files of at least align bytes at a
+// piece boundary.
+func contentLength(inputs []inputFile, plen, align int64) int64 {
+	var total int64
+	for _, input := range inputs {
+		if aligned(input, align) && total%plen != 0 {
+			total += plen - total%plen
+		}
+		total += input.size
+	}
+	return total
+}
+
+// infoFlags are fields of the info dictionary set from flags.  They are set
+// before hashing completes so the info hash is final.
+type infoFlags struct {
+	private bool
+	source  string
+}
+
+func (f infoFlags) set(info *metainfo.Info) {
+	info.Private = f.private
+	info.Source = f.source
+}
+
+// createTorrent hashes inputs with a metainfo.Writer using jobs concurrent
+// hashing goroutines.  If single is true the torrent is created in
+// single-file mode.  Otherwise, if align is positive, files of at least align
+// bytes are preceded by padding so they start at a piece boundary.
+func createTorrent(name string, single bool, inputs []inputFile, plen, align int64, jobs int, flags infoFlags, announce string, prog *progress) (*metainfo.Metainfo, error) {
+	var w *metainfo.Writer
+	var err error
+	if single {
+		w, err = metainfo.NewWriterSingle(plen, name)
+	} else {
+		w, err = metainfo.NewWriter(plen)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close() // stop hashing goroutines on error
+	err = w.SetConcurrency(jobs)
+	if err != nil {
+		return nil, err
+	}
+	w.SetPrivate(flags.private)
+	w.SetSource(flags.source)
+	for _, input := range inputs {
+		if !single && aligned(input, align) {
+			err := w.Pad()
+			if err != nil {
+				return nil, err
+			}
+		}
+		err := hashFile(w, input, prog)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return w.Metainfo(name, announce)
+}
+
+// hashFile writes the contents of input to w as a new file entry.  If input
+// has no metainfo path w is assumed to be a single-file writer.
+func hashFile(w *metainfo.Writer, input inputFile, prog *progress) error {
+	if input.link != nil {
+		return w.Symlink(input.link, input.meta...)
+	}
+	f, err := os.Open(input.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if len(input.meta) > 0 {
+		err = w.Open(input.meta...)
+		if err != nil {
+			return err
+		}
+	}
+	prog.SetFile(input.path)
+	_, err = io.Copy(w, io.TeeReader(f, prog))
+	return err
+}
+
+// symlinkMode determines how symbolic links are added to a torrent.
+type symlinkMode int
+
+const (
+	followSymlinks symlinkMode = iota // add the link target's content
+	skipSymlinks                      // omit symbolic links
+	recordSymlinks                    // add BEP 47 symlink entries
+)
+
+// walker collects the files in a directory tree.
+type walker struct {
+	excludes []string
+	links    symlinkMode
+	hidden   bool // walk hidden files and directories
+	inputs   []inputFile
+	skipped  []skippedFile
+	visited  map[string]bool // directories being walked, to detect loops
+}
+
+// skippedFile is a file or directory that could not be read.
+type skippedFile struct {
+	path string
+	err  error
+}
+
+// skip records path as skipped if err is a permission error.  Otherwise err
+// is returned.
+func (wk *walker) skip(path string, err error) error {
+	if !os.IsPermission(err) {
+		return err
+	}
+	wk.skipped = append(wk.skipped, skippedFile{path, err})
+	return nil
+}
+
+func newWalker(excludes []string, links symlinkMode) *walker {
+	return &walker{
+		excludes: excludes,
+		links:    links,
+		visited:  make(map[string]bool),
+	}
+}
+
+// walk adds the file at path, described by info, to wk.inputs with the
+// metainfo path meta.  If path is a directory its contents are walked.
+func (wk *walker) walk(path string, meta []string, info os.FileInfo) error {
+	if len(meta) > 0 {
+		skip, err := excluded(wk.excludes, filepath.Join(meta...))
+		if err != nil {
+			return err
+		}
+		if skip {
+			return nil
+		}
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return wk.symlink(path, meta)
+	case info.IsDir():
+		return wk.dir(path, meta)
+	}
+	return wk.file(path, meta, info)
+}
+
+// file adds the regular file at path to wk.inputs if it can be opened.
+func (wk *walker) file(path string, meta []string, info os.FileInfo) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return wk.skip(path, err)
+	}
+	f.Close()
+	wk.inputs = append(wk.inputs, inputFile{
+		path:  path,
+		meta:  meta,
+		size:  info.Size(),
+		mtime: info.ModTime().UnixNano(),
+	})
+	return nil
+}
+
+func (wk *walker) dir(path string, meta []string) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if wk.visited[real] {
+		return fmt.Errorf("symlink loop: %q", path)
+	}
+	wk.visited[real] = true
+	defer delete(wk.visited, real)
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		return wk.skip(path, err)
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if !wk.hidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		child := append(meta[:len(meta):len(meta)], name)
+		err := wk.walk(filepath.Join(path, name), child, info)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (wk *walker) symlink(path string, meta []string) error {
+	if wk.links == skipSymlinks {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("dangling symlink: %q", path)
+	}
+	if err != nil {
+		return err
+	}
+	if wk.links == followSymlinks {
+		if info.IsDir() {
+			return wk.dir(path, meta)
+		}
+		return wk.file(path, meta, info)
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+	if len(meta) == 0 || filepath.IsAbs(target) {
+		return fmt.Errorf("symlink target is outside of the torrent: %q", path)
+	}
+	target = filepath.Join(filepath.Join(meta[:len(meta)-1]...), target)
+	if target == ".." || strings.HasPrefix(target, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("symlink target is outside of the torrent: %q", path)
+	}
+	link := strings.Split(filepath.ToSlash(target), "/")
+	wk.inputs = append(wk.inputs, inputFile{path: path, meta: meta, link: link})
+	return nil
+}
+
+// walkInputs walks files and returns the files found which are not excluded,
+// and those which were skipped because they could not be read.
+// If multiple files are given each is a top-level entry in the torrent.
+// Symbolic links given as inputs are always followed.  Hidden files within
+// directories are skipped unless hidden is true.
+func walkInputs(files []string, excludes []string, links symlinkMode, hidden bool) ([]inputFile, []skippedFile, error) {
+	wk := newWalker(excludes, links)
+	wk.hidden = hidden
+	for _, filename := range files {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		var meta []string
+		if len(files) > 1 {
+			meta = []string{filepath.Base(filename)}
+		}
+		err = wk.walk(filename, meta, info)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return wk.inputs, wk.skipped, nil
+}
+
+// readFileList reads a list of file paths from filename ("-" reads from
+// stdin).  Paths are delimited by NUL bytes if the list contains any,
+// otherwise they are delimited by newlines.
+func readFileList(filename string) ([]string, error) {
+	var p []byte
+	var err error
+	if filename == "-" {
+		p, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		p, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sep := "\n"
+	if bytes.IndexByte(p, 0) >= 0 {
+		sep = "\x00"
+	}
+	var list []string
+	for _, path := range strings.Split(string(p), sep) {
+		if sep == "\n" {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path != "" {
+			list = append(list, path)
+		}
+	}
+	return list, nil
+}
+
+// listInputs returns the files in list, in order, which are not excluded, and
+// those which were skipped because they could not be read.
+// Directories in list are ignored.  Each path must be relative and is used as
+// the file's path within the torrent.
+func listInputs(list []string, excludes []string, links symlinkMode) ([]inputFile, []skippedFile, error) {
+	wk := newWalker(excludes, links)
+	for _, path := range list {
+		metap := filepath.Clean(path)
+		if filepath.IsAbs(metap) || metap == ".." || strings.HasPrefix(metap, ".."+string(filepath.Separator)) {
+			return nil, nil, fmt.Errorf("path is not within the working directory: %q", path)
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err == nil && target.IsDir() {
+				continue
+			}
+		}
+		metaps := strings.Split(filepath.ToSlash(metap), "/")
+		err = wk.walk(path, metaps, info)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return wk.inputs, wk.skipped, nil
+}
+
 func main() {
 	force := flag.Bool("f", false, "overwrite existing torrent file")
-	outpath := flag.String("o", "", "path of output torrent file")
+	outpath := flag.String("o", "", "path of output torrent file (- for stdout)")
 	private := flag.Bool("p", false, "make a private torrent")
 	comment := flag.String("c", "", "comment text")
 	rec := flag.Bool("r", false, "recursively add files in directories")
 	id := flag.String("id", "com.github.bmatsuo.torrent.cmd.mktorrent/0.0", "program identity")
+	var excludes stringsFlag
+	flag.Var(&excludes, "exclude", "glob pattern of files to omit (may be repeated)")
+	excludeFrom := flag.String("exclude-from", "", "file containing exclusion patterns, one per line")
+	var quiet bool
+	flag.BoolVar(&quiet, "q", false, "do not report hashing progress")
+	flag.BoolVar(&quiet, "quiet", false, "do not report hashing progress")
+	var name string
+	flag.StringVar(&name, "n", "", "torrent name (default: base name of the input)")
+	flag.StringVar(&name, "name", "", "torrent name (default: base name of the input)")
+	source := flag.String("source", "", "source tag for private trackers (stored in the info dictionary)")
+	noDate := flag.Bool("no-date", false, "omit the creation date")
+	noCreatedBy := flag.Bool("no-created-by", false, "omit the program identity")
+	filesFrom := flag.String("files-from", "", "read the files to add from a newline or NUL delimited list (- for stdin)")
+	followLinks := flag.Bool("follow-symlinks", false, "add the content of symlink targets (default)")
+	skipLinks := flag.Bool("skip-symlinks", false, "omit symlinks")
+	recordLinks := flag.Bool("record-symlinks", false, "add symlinks as BEP 47 symlink entries")
+	keepOrder := flag.Bool("keep-order", false, "add files from -files-from in the order listed instead of sorting them")
+	hidden := flag.Bool("include-hidden", false, "add hidden files and directories")
+	var trackers stringsFlag
+	flag.Var(&trackers, "a", "announce url (may be repeated to add backup trackers)")
+	var nodes stringsFlag
+	flag.Var(&nodes, "node", "DHT bootstrap node host:port for trackerless torrents (may be repeated)")
+	plenExp := flag.Int("l", 19, "piece length as a power of two (2^n bytes)")
+	jobs := flag.Int("j", 0, "number of pieces hashed concurrently (default: the number of CPUs)")
+	cachePath := flag.String("cache", "", "file recording piece hashes so unchanged data is not rehashed on later runs")
+	dryRun := flag.Bool("dry-run", false, "report the torrent layout without hashing or writing anything")
+	align := flag.Int64("align", 0, "start files of at least this many bytes at piece boundaries using padding files, ordering them before smaller files")
+	eachSubdir := flag.Bool("each-subdir", false, "create one torrent for each immediate subdirectory of the input directory, written to the -o directory")
+	watch := flag.Bool("watch", false, "poll the inputs and write a new numbered torrent whenever they change")
+	watchInterval := flag.Duration("watch-interval", 2*time.Second, "polling interval for -watch")
+	configPath := flag.String("config", "", "configuration file (default: $XDG_CONFIG_HOME/mktorrent.toml)")
 	flag.Parse()
-	args := flag.Args()
-	if len(args) < 2 {
-		log.Fatal("usage: %s [flags] <announce> <file> ...")
+	log.SetFlags(0)
+	log.SetPrefix("mktorrent: ")
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var cfg config
+	if *configPath != "" {
+		err := readConfig(*configPath, &cfg)
+		if err != nil {
+			fatalf(exitUsage, "%v", err)
+		}
+	} else if path := defaultConfigPath(); path != "" {
+		err := readConfig(path, &cfg)
+		if err != nil && !os.IsNotExist(err) {
+			fatalf(exitUsage, "%v", err)
+		}
 	}
-	announce, files := args[0], args[1:]
-	w, err := metainfo.NewWriter(512 << 10)
+	err := cfg.readEnv()
 	if err != nil {
-		log.Fatal("couldn't created torrent writer: %v", err)
+		fatalf(exitUsage, "%v", err)
+	}
+	if !set["a"] && len(cfg.Announce) > 0 {
+		trackers = cfg.Announce
+	}
+	if !set["source"] && cfg.Source != "" {
+		*source = cfg.Source
+	}
+	if !set["l"] && cfg.PieceLength != 0 {
+		*plenExp = cfg.PieceLength
 	}
+	if !set["id"] && cfg.CreatedBy != "" {
+		*id = cfg.CreatedBy
+	}
+	if *plenExp < 15 || *plenExp > 28 {
+		fatalf(exitUsage, "piece length exponent out of range [15, 28]: %d", *plenExp)
+	}
+	plen := int64(1) << uint(*plenExp)
+	var links symlinkMode
+	switch {
+	case *followLinks && (*skipLinks || *recordLinks), *skipLinks && *recordLinks:
+		fatalf(exitUsage, "only one of -follow-symlinks, -skip-symlinks, and -record-symlinks may be given")
+	case *skipLinks:
+		links = skipSymlinks
+	case *recordLinks:
+		links = recordSymlinks
+	}
+	if *align > 0 && *cachePath != "" {
+		fatalf(exitUsage, "-align cannot be used with -cache")
+	}
+	if *excludeFrom != "" {
+		patterns, err := readPatterns(*excludeFrom)
+		if err != nil {
+			fatalf(exitIO, "%v", err)
+		}
+		excludes = append(excludes, patterns...)
+	}
+	for _, pattern := range excludes {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			fatalf(exitUsage, "invalid exclude pattern %q: %v", pattern, err)
+		}
+	}
+	files := flag.Args()
+	if !set["a"] && len(files) > 0 && strings.Contains(files[0], "://") {
+		trackers, files = stringsFlag{files[0]}, files[1:]
+	}
+	if len(trackers) == 0 && len(nodes) == 0 || *filesFrom != "" && len(files) != 0 || *filesFrom == "" && len(files) == 0 {
+		fatalf(exitUsage, "usage: %s [flags] [<announce>] <file> ...", os.Args[0])
+	}
+	var dhtNodes []metainfo.Node
+	for _, node := range nodes {
+		host, port, err := net.SplitHostPort(node)
+		if err != nil {
+			fatalf(exitUsage, "invalid node: %v", err)
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			fatalf(exitUsage, "invalid node port: %q", node)
+		}
+		dhtNodes = append(dhtNodes, metainfo.Node{Host: host, Port: n})
+	}
+	if *private {
+		// private torrents may only find peers through their trackers.
+		if len(trackers) == 0 {
+			fatalf(exitUsage, "private torrents require an announce url")
+		}
+		if len(dhtNodes) > 0 {
+			fatalf(exitUsage, "-node cannot be used with -p: private torrents may not use the DHT")
+		}
+	}
+	flags := infoFlags{private: *private, source: *source}
+	var announce string
+	if len(trackers) > 0 {
+		announce = trackers[0]
+	}
+	if *eachSubdir {
+		switch {
+		case len(files) != 1 || *filesFrom != "":
+			fatalf(exitUsage, "-each-subdir requires a single input directory")
+		case name != "" || *watch || *cachePath != "":
+			fatalf(exitUsage, "-each-subdir cannot be used with -name, -watch, or -cache")
+		}
+		*rec = true
+	}
+	var single bool
 	for _, filename := range files {
 		info, err := os.Stat(filename)
 		if err != nil {
-			log.Fatal("%q %v", filename, err)
+			fatalf(exitIO, "%v", err)
 		}
 		if !*rec && info.IsDir() {
-			log.Fatal("directory specified without -r: %q ", filename)
+			fatalf(exitUsage, "directory specified without -r: %q", filename)
 		}
+		single = len(files) == 1 && !info.IsDir()
 	}
-	for _, filename := range files {
-		err := filepath.Walk(filename, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
+	if *eachSubdir && single {
+		fatalf(exitUsage, "-each-subdir requires a single input directory")
+	}
+	if name == "" && len(files) != 1 {
+		fatalf(exitUsage, "-name is required unless a single input is given")
+	}
+	if name == "" {
+		name = filepath.Base(files[0])
+	}
+	gather := func() (inputs []inputFile, skipped []skippedFile, err error) {
+		if *filesFrom != "" {
+			var list []string
+			list, err = readFileList(*filesFrom)
+			if err == nil {
+				inputs, skipped, err = listInputs(list, excludes, links)
+			}
+		} else {
+			inputs, skipped, err = walkInputs(files, excludes, links, *hidden)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if *filesFrom == "" || !*keepOrder {
+			sort.Stable(inputsByPath(inputs))
+			if *align > 0 && !single {
+				sort.Stable(alignedFirst{inputs, *align})
 			}
-			if info.IsDir() { // rec check would be redundant
-				return nil
+		}
+		return inputs, skipped, nil
+	}
+	if single {
+		*align = 0
+	}
+	if *outpath == "" && !*eachSubdir {
+		*outpath = fmt.Sprintf("%s.torrent", name)
+	}
+
+	// cache is kept in memory between builds in watch mode.
+	var cache *hashCache
+	if *cachePath != "" {
+		cache, err = readCache(*cachePath)
+		if err != nil {
+			fatalf(exitIO, "could not read cache: %v", err)
+		}
+	} else if *watch && *align == 0 {
+		cache = new(hashCache)
+	}
+
+	build := func(inputs []inputFile, outpath string) error {
+		var total int64
+		for _, input := range inputs {
+			total += input.size
+		}
+		var prog *progress
+		if !quiet {
+			prog = newProgress(os.Stderr, total)
+		}
+		var meta *metainfo.Metainfo
+		var err error
+		if cache != nil {
+			meta, err = createCached(name, single, inputs, plen, flags, announce, cache, prog)
+			if err == nil && *cachePath != "" {
+				err = writeCache(*cachePath, cache)
 			}
-			metap, err := filepath.Rel(filename, path)
-			if err != nil {
-				return err
+		} else {
+			meta, err = createTorrent(name, single, inputs, plen, *align, *jobs, flags, announce, prog)
+		}
+		prog.Done()
+		if err != nil {
+			return exitError{exitIO, fmt.Errorf("could not create torrent: %v", err)}
+		}
+		if len(trackers) > 1 {
+			for _, tracker := range trackers {
+				meta.AnnounceList = append(meta.AnnounceList, []string{tracker})
 			}
-			var metaps []string
-			var base string
-			for metap != "" && metap != "." {
-				metap, base = filepath.Dir(metap), filepath.Base(metap)
-				metaps = append(metaps, "")
-				copy(metaps, metaps[1:])
-				metaps[0] = base
+		}
+		meta.Nodes = dhtNodes
+		if !*noDate {
+			meta.CreationDate = time.Now().Unix()
+		}
+		if !*noCreatedBy {
+			meta.CreatedBy = *id
+		}
+		meta.Comment = *comment
+		p, err := bencoding.Marshal(meta)
+		if err != nil {
+			return exitError{exitEncoding, fmt.Errorf("could not encode torrent: %v", err)}
+		}
+		outf := os.Stdout
+		if outpath != "-" {
+			mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC | os.O_EXCL
+			if *force {
+				mode ^= os.O_EXCL
 			}
-
-			f, err := os.Open(path)
+			outf, err = os.OpenFile(outpath, mode, 0640)
 			if err != nil {
-				return err
+				return exitError{exitIO, err}
 			}
-			defer f.Close()
-			err = w.Open(metaps...)
-			if err != nil {
-				return err
+		}
+		_, err = outf.Write(p)
+		if err == nil && outf != os.Stdout {
+			err = outf.Close()
+		}
+		if err != nil {
+			return exitError{exitIO, fmt.Errorf("could not write torrent: %v", err)}
+		}
+		return nil
+	}
+
+	if *eachSubdir {
+		outdir := *outpath
+		if outdir == "" {
+			outdir = "."
+		}
+		dirs, err := subdirs(files[0], excludes, *hidden)
+		if err != nil {
+			fatalf(exitIO, "%v", err)
+		}
+		if len(dirs) == 0 {
+			fatalf(exitUsage, "no subdirectories in %q", files[0])
+		}
+		code := 0
+		for _, dir := range dirs {
+			// gather and build read the input and name of each torrent.
+			files, name = []string{dir}, filepath.Base(dir)
+			inputs, skipped, err := gather()
+			if err == nil && *dryRun {
+				printLayout(name, inputs, plen, *align)
+				continue
+			}
+			if err == nil {
+				err = build(inputs, filepath.Join(outdir, name+".torrent"))
 			}
-			_, err = io.Copy(w, f)
 			if err != nil {
-				return err
+				log.Printf("%s: %v", dir, err)
+				code = exitIO
+				if err, ok := err.(exitError); ok {
+					code = err.code
+				}
+				continue
 			}
+			logSkipped(skipped)
+		}
+		os.Exit(code)
+	}
 
-			return nil
-		})
-		if err != nil {
-			log.Fatal(err)
+	if *watch {
+		if *outpath == "-" || *dryRun {
+			fatalf(exitUsage, "-watch cannot be used with -dry-run or output to stdout")
 		}
+		watchInputs(*watchInterval, *outpath, gather, build)
+		return
 	}
 
-	name := filepath.Base(files[0])
-	meta, err := w.Metainfo(name, announce)
+	inputs, skipped, err := gather()
 	if err != nil {
-		log.Fatal("could not create torrent: %v", err)
+		fatalf(exitIO, "%v", err)
 	}
-	meta.CreationDate = time.Now().Unix()
-	meta.CreatedBy = *id
-	meta.Comment = *comment
-	meta.Info.Private = *private
-	if *outpath == "" {
-		*outpath = fmt.Sprintf("%s.torrent", name)
+	if *dryRun {
+		printLayout(name, inputs, plen, *align)
+		return
 	}
-	mode := os.O_WRONLY | os.O_CREATE | os.O_APPEND | os.O_EXCL
-	if *force {
-		mode ^= os.O_EXCL
+	err = build(inputs, *outpath)
+	if err, ok := err.(exitError); ok {
+		fatalf(err.code, "%v", err.err)
 	}
-	outf, err := os.OpenFile(*outpath, mode, 0640)
-	outbuf := bufio.NewWriter(outf)
-	if err != nil {
-		log.Fatal(err)
+	logSkipped(skipped)
+}
+
+// printLayout reports the layout of a torrent for -dry-run.
+func printLayout(name string, inputs []inputFile, plen, align int64) {
+	var total int64
+	for _, input := range inputs {
+		total += input.size
 	}
-	defer outf.Close()
-	err = bencoding.NewEncoder(outbuf).Encode(meta)
-	if err != nil {
-		log.Fatal("could not write torrent: %v", err)
+	content := contentLength(inputs, plen, align)
+	fmt.Printf("name:         %s\n", name)
+	fmt.Printf("files:        %d\n", len(inputs))
+	fmt.Printf("total size:   %d\n", total)
+	if content != total {
+		fmt.Printf("padding:      %d\n", content-total)
 	}
-	err = outbuf.Flush()
+	fmt.Printf("piece length: %d\n", plen)
+	fmt.Printf("pieces:       %d\n", (content+plen-1)/plen)
+}
+
+// subdirs returns the immediate subdirectories of dir in sorted order,
+// omitting excluded and hidden directories like walkInputs.
+func subdirs(dir string, excludes []string, hidden bool) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
 	if err != nil {
-		log.Fatal("could not flush torrent content: %v", err)
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !hidden && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		skip, err := excluded(excludes, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			continue
+		}
+		dirs = append(dirs, filepath.Join(dir, entry.Name()))
+	}
+	return dirs, nil
+}
+
+// exitError is an error which causes mktorrent to exit with a given code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (err exitError) Error() string {
+	return err.err.Error()
+}
+
+func logSkipped(skipped []skippedFile) {
+	if len(skipped) > 0 {
+		log.Printf("skipped %d unreadable files:", len(skipped))
+		for _, skip := range skipped {
+			log.Printf("  %v", skip.err)
+		}
 	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressInterval is the minimum time between progress updates.
+const progressInterval = 250 * time.Millisecond
+
+// progress reports hashing progress on a terminal.  A nil *progress discards
+// all updates, so callers need not check for quiet mode.
+type progress struct {
+	w     io.Writer
+	total int64
+	done  int64
+	file  string
+	start time.Time
+	last  time.Time
+}
+
+func newProgress(w io.Writer, total int64) *progress {
+	now := time.Now()
+	return &progress{
+		w:     w,
+		total: total,
+		start: now,
+		last:  now,
+	}
+}
+
+// SetFile changes the file name displayed with progress updates.
+func (p *progress) SetFile(name string) {
+	if p == nil {
+		return
+	}
+	p.file = name
+	p.print()
+}
+
+// Write counts the bytes of b as hashed.  It never returns an error, so a
+// progress can be used with io.TeeReader.
+func (p *progress) Write(b []byte) (int, error) {
+	if p == nil {
+		return len(b), nil
+	}
+	p.done += int64(len(b))
+	if time.Since(p.last) >= progressInterval {
+		p.print()
+	}
+	return len(b), nil
+}
+
+// Skip removes n bytes which need not be hashed from the total.
+func (p *progress) Skip(n int64) {
+	if p == nil {
+		return
+	}
+	p.total -= n
+}
+
+// Done prints a final progress update and terminates the progress line.
+func (p *progress) Done() {
+	if p == nil {
+		return
+	}
+	p.file = ""
+	p.print()
+	fmt.Fprintln(p.w)
+}
+
+func (p *progress) print() {
+	now := time.Now()
+	p.last = now
+	elapsed := now.Sub(p.start).Seconds()
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed
+	}
+	percent := 100.0
+	if p.total > 0 {
+		percent = 100 * float64(p.done) / float64(p.total)
+	}
+	eta := "--:--"
+	if rate > 0 {
+		remain := time.Duration(float64(p.total-p.done)/rate) * time.Second
+		eta = formatDuration(remain)
+	}
+	fmt.Fprintf(p.w, "\r\x1b[K%5.1f%% %8.2f MB/s ETA %s %s",
+		percent, rate/(1<<20), eta, p.file)
+}
+
+// formatDuration formats d as minutes and seconds (or hours, minutes, and
+// seconds for long durations).
+func formatDuration(d time.Duration) string {
+	s := int64(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
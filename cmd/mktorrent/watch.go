@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// inputsDigest summarizes the paths, sizes and modification times of inputs
+// so that changes can be detected between polls.
+func inputsDigest(inputs []inputFile) string {
+	h := sha1.New()
+	for _, input := range inputs {
+		fmt.Fprintf(h, "%q %q %d %d\n", input.path, input.meta, input.size, input.mtime)
+	}
+	return string(h.Sum(nil))
+}
+
+// versionedPath inserts the version n before the extension of path, so that
+// "name.torrent" becomes "name.3.torrent".
+func versionedPath(path string, n int) string {
+	if strings.HasSuffix(path, ".torrent") {
+		return fmt.Sprintf("%s.%d.torrent", strings.TrimSuffix(path, ".torrent"), n)
+	}
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// watchInputs polls the inputs every interval and builds a new version of
+// the torrent each time they change.  Except on the first poll, inputs must
+// be unchanged for a full interval before they are rebuilt, so files which
+// are still being written are not hashed.  Errors are logged and the build
+// is retried on the next change.  watchInputs never returns.
+func watchInputs(interval time.Duration, outpath string, gather func() ([]inputFile, []skippedFile, error), build func([]inputFile, string) error) {
+	var built, last string
+	version := 0
+	for ; ; time.Sleep(interval) {
+		inputs, skipped, err := gather()
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		digest := inputsDigest(inputs)
+		stable := digest == last || last == ""
+		last = digest
+		if !stable || digest == built {
+			continue
+		}
+		for {
+			version++
+			_, err := os.Stat(versionedPath(outpath, version))
+			if os.IsNotExist(err) {
+				break
+			}
+		}
+		path := versionedPath(outpath, version)
+		err = build(inputs, path)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		built = digest
+		log.Printf("wrote %s (%d files)", path, len(inputs))
+		logSkipped(skipped)
+	}
+}
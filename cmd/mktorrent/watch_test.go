@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// TestWatchInputs checks that each version built in watch mode, which reuses
+// an in-memory cache between builds, has the hash of an uncached build.
+func TestWatchInputs(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "content")
+	writeTestFile(t, path, 3*testPieceLength+5, 1)
+
+	cache := new(hashCache)
+	// the watcher outlives the test, so gather must not use t.
+	gather := func() ([]inputFile, []skippedFile, error) {
+		return walkInputs([]string{path}, nil, followSymlinks, false)
+	}
+	built := make(chan metainfo.InfoHash)
+	build := func(inputs []inputFile, outpath string) error {
+		meta, err := createCached("content", true, inputs, testPieceLength, infoFlags{}, "", cache, nil)
+		if err != nil {
+			return err
+		}
+		err = metainfo.WriteFile(outpath, meta, 0644)
+		if err != nil {
+			return err
+		}
+		hash, err := meta.Info.Hash()
+		if err != nil {
+			return err
+		}
+		built <- hash
+		return nil
+	}
+	go watchInputs(10*time.Millisecond, filepath.Join(dir, "content.torrent"), gather, build)
+
+	for i, size := range []int{0, 2*testPieceLength + 1} {
+		if i > 0 {
+			writeTestFile(t, path, size, int64(i+1))
+		}
+		expect := uncachedHash(t, path, true)
+		select {
+		case h := <-built:
+			if h != expect {
+				t.Errorf("version %d: hash %v (!= %v)", i+1, h, expect)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("version %d was not built", i+1)
+		}
+	}
+}
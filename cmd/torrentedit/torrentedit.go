@@ -0,0 +1,198 @@
+/*
+Command torrentedit modifies the metadata of a torrent file outside of its
+info dictionary.  The info dictionary is preserved byte-for-byte so the
+torrent's info hash does not change.
+
+	torrentedit [flags] <torrent>
+
+Only the fields named by flags are changed.  Unless -o is given the torrent
+is rewritten in place.  The info hash is printed on completion.
+*/
+package main
+
+import (
+	"crypto/sha1"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatsuo/torrent/bencoding"
+)
+
+// stringsFlag is a flag.Value that accumulates repeated flag values.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// parseDate parses a creation date given as "now", unix seconds, or an RFC
+// 3339 timestamp.
+func parseDate(s string) (int64, error) {
+	if s == "now" {
+		return time.Now().Unix(), nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q", s)
+	}
+	return t.Unix(), nil
+}
+
+// torrent is a decoded torrent file with each top-level value in its
+// original encoding.
+type torrent map[string]bencoding.RawMessage
+
+// set replaces the value of key with the encoding of v.
+func (t torrent) set(key string, v interface{}) error {
+	p, err := bencoding.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	t[key] = p
+	return nil
+}
+
+func (t torrent) marshal() ([]byte, error) {
+	m := make(map[string]interface{}, len(t))
+	for k, v := range t {
+		m[k] = v
+	}
+	return bencoding.Marshal(m)
+}
+
+// writeFile atomically replaces filename with p, keeping its permissions.
+func writeFile(filename string, p []byte) error {
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(filename), ".torrentedit")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(p)
+	if err == nil {
+		err = f.Chmod(stat.Mode().Perm())
+	}
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func main() {
+	var trackers stringsFlag
+	flag.Var(&trackers, "a", "announce url replacing existing trackers (may be repeated)")
+	clearTrackers := flag.Bool("clear-trackers", false, "remove all trackers")
+	var webseeds stringsFlag
+	flag.Var(&webseeds, "w", "web seed url replacing existing web seeds (may be repeated)")
+	clearWebseeds := flag.Bool("clear-webseeds", false, "remove all web seeds")
+	comment := flag.String("c", "", "comment text (an empty comment removes it)")
+	date := flag.String("date", "", "creation date as unix seconds, RFC 3339, or \"now\" (empty removes it)")
+	outpath := flag.String("o", "", "output path (- for stdout; default: modify the torrent in place)")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("torrentedit: ")
+	if flag.NArg() != 1 {
+		log.Fatalf("usage: %s [flags] <torrent>", os.Args[0])
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	filename := flag.Arg(0)
+	p, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := make(torrent)
+	err = bencoding.Unmarshal(p, &t)
+	if err != nil {
+		log.Fatalf("%s: %v", filename, err)
+	}
+	info, ok := t["info"]
+	if !ok {
+		log.Fatalf("%s: missing info dictionary", filename)
+	}
+
+	if *clearTrackers || len(trackers) > 0 {
+		delete(t, "announce")
+		delete(t, "announce-list")
+	}
+	if len(trackers) > 0 {
+		err = t.set("announce", trackers[0])
+	}
+	if err == nil && len(trackers) > 1 {
+		var tiers [][]string
+		for _, tracker := range trackers {
+			tiers = append(tiers, []string{tracker})
+		}
+		err = t.set("announce-list", tiers)
+	}
+	if *clearWebseeds || len(webseeds) > 0 {
+		delete(t, "url-list")
+	}
+	if err == nil && len(webseeds) > 0 {
+		err = t.set("url-list", []string(webseeds))
+	}
+	if err == nil && set["c"] {
+		delete(t, "comment")
+		if *comment != "" {
+			err = t.set("comment", *comment)
+		}
+	}
+	if err == nil && set["date"] {
+		delete(t, "creation date")
+		if *date != "" {
+			var n int64
+			n, err = parseDate(*date)
+			if err == nil {
+				err = t.set("creation date", n)
+			}
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	p, err = t.marshal()
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := os.Stdout
+	switch *outpath {
+	case "-":
+		out = os.Stderr
+		_, err = os.Stdout.Write(p)
+	case "":
+		err = writeFile(filename, p)
+	default:
+		err = ioutil.WriteFile(*outpath, p, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(out, "%x\n", sha1.Sum(info))
+}
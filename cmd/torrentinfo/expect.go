@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatsuo/torrent/magnet"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// parseExpected parses the argument of the -expect flag, either a hex or
+// base32 encoded info hash or a magnet link containing a btih exact topic.
+func parseExpected(s string) (metainfo.InfoHash, error) {
+	if !strings.HasPrefix(s, "magnet:") {
+		return metainfo.ParseInfoHash(s)
+	}
+	var h metainfo.InfoHash
+	m, err := magnet.Parse(s)
+	if err != nil {
+		return h, err
+	}
+	if m.InfoHash == nil {
+		return h, fmt.Errorf("magnet link has no btih exact topic")
+	}
+	copy(h[:], m.InfoHash)
+	return h, nil
+}
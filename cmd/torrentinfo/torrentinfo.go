@@ -0,0 +1,182 @@
+/*
+Command torrentinfo prints information about torrent metainfo files.
+
+	torrentinfo [-json] [-expect <infohash|magnet>] <torrent> ...
+
+When -expect is given torrentinfo exits with status 1 if the info hash of any
+torrent does not match the expected hash, so it can guard download pipelines
+against substituted files.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// torrentInfo is a summary of a torrent file.  It is the output format of
+// the -json flag.
+type torrentInfo struct {
+	File         string     `json:"file"`
+	Name         string     `json:"name"`
+	InfoHash     string     `json:"info_hash"`
+	PieceLength  int64      `json:"piece_length"`
+	Pieces       int        `json:"pieces"`
+	TotalSize    int64      `json:"total_size"`
+	Private      bool       `json:"private"`
+	Source       string     `json:"source,omitempty"`
+	Trackers     [][]string `json:"trackers"`
+	Nodes        []string   `json:"nodes,omitempty"`
+	WebSeeds     []string   `json:"web_seeds,omitempty"`
+	Files        []fileInfo `json:"files"`
+	CreatedBy    string     `json:"created_by,omitempty"`
+	CreationDate string     `json:"creation_date,omitempty"`
+	Comment      string     `json:"comment,omitempty"`
+}
+
+type fileInfo struct {
+	Path   string `json:"path"`
+	Length int64  `json:"length"`
+}
+
+// newTorrentInfo summarizes meta, whose info dictionary has the given hash.
+func newTorrentInfo(filename string, meta *metainfo.Metainfo, hash metainfo.InfoHash) *torrentInfo {
+	// display names in UTF-8 when the torrent's encoding is known.
+	if utf, err := meta.UTF8Names(); err == nil {
+		meta = utf
+	}
+	info := &torrentInfo{
+		File:        filename,
+		Name:        meta.Info.Name,
+		InfoHash:    hash.Hex(),
+		PieceLength: meta.Info.PieceLength,
+		Pieces:      len(meta.Info.Pieces) / 20,
+		Private:     meta.Info.Private,
+		Source:      meta.Info.Source,
+		Trackers:    meta.AnnounceList,
+		WebSeeds:    meta.URLList,
+		CreatedBy:   meta.CreatedBy,
+		Comment:     meta.Comment,
+	}
+	if len(info.Trackers) == 0 && meta.Announce != "" {
+		info.Trackers = [][]string{{meta.Announce}}
+	}
+	for _, node := range meta.Nodes {
+		info.Nodes = append(info.Nodes, node.String())
+	}
+	if meta.CreationDate != 0 {
+		info.CreationDate = time.Unix(meta.CreationDate, 0).UTC().Format(time.RFC3339)
+	}
+	if meta.Info.SingleFileMode() {
+		info.TotalSize = meta.Info.Length
+		info.Files = []fileInfo{{meta.Info.Name, meta.Info.Length}}
+	}
+	for _, file := range meta.Info.Files {
+		info.TotalSize += file.Length
+		p := path.Join(append([]string{meta.Info.Name}, file.Path...)...)
+		info.Files = append(info.Files, fileInfo{p, file.Length})
+	}
+	return info
+}
+
+func (info *torrentInfo) print() {
+	fmt.Printf("file:          %s\n", info.File)
+	fmt.Printf("name:          %s\n", info.Name)
+	fmt.Printf("info hash:     %s\n", info.InfoHash)
+	fmt.Printf("piece length:  %d\n", info.PieceLength)
+	fmt.Printf("pieces:        %d\n", info.Pieces)
+	fmt.Printf("total size:    %d\n", info.TotalSize)
+	fmt.Printf("private:       %t\n", info.Private)
+	if info.Source != "" {
+		fmt.Printf("source:        %s\n", info.Source)
+	}
+	if info.CreatedBy != "" {
+		fmt.Printf("created by:    %s\n", info.CreatedBy)
+	}
+	if info.CreationDate != "" {
+		fmt.Printf("creation date: %s\n", info.CreationDate)
+	}
+	if info.Comment != "" {
+		fmt.Printf("comment:       %s\n", info.Comment)
+	}
+	if len(info.Trackers) == 0 {
+		fmt.Printf("trackers:      none (trackerless)\n")
+	} else {
+		fmt.Printf("trackers:\n")
+	}
+	for i, tier := range info.Trackers {
+		fmt.Printf("  tier %d: %s\n", i+1, strings.Join(tier, " "))
+	}
+	if len(info.Nodes) > 0 {
+		fmt.Printf("dht nodes:\n")
+		for _, node := range info.Nodes {
+			fmt.Printf("  %s\n", node)
+		}
+	}
+	if len(info.WebSeeds) > 0 {
+		fmt.Printf("web seeds:\n")
+		for _, seed := range info.WebSeeds {
+			fmt.Printf("  %s\n", seed)
+		}
+	}
+	fmt.Printf("files:\n")
+	for _, file := range info.Files {
+		fmt.Printf("  %12d %s\n", file.Length, file.Path)
+	}
+}
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print information as a stream of JSON objects")
+	expect := flag.String("expect", "", "exit nonzero unless the info hash matches this hash or magnet link")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("torrentinfo: ")
+	if flag.NArg() == 0 {
+		log.Fatalf("usage: %s [flags] <torrent> ...", os.Args[0])
+	}
+	var expected string
+	if *expect != "" {
+		hash, err := parseExpected(*expect)
+		if err != nil {
+			log.Fatalf("-expect: %v", err)
+		}
+		expected = hash.Hex()
+	}
+	mismatch := false
+	enc := json.NewEncoder(os.Stdout)
+	for i, filename := range flag.Args() {
+		// the hash of the original encoding includes info keys unknown to
+		// metainfo.Info.
+		meta, hash, err := metainfo.ReadFileWithHash(filename)
+		if err != nil {
+			log.Fatalf("%s: %v", filename, err)
+		}
+		info := newTorrentInfo(filename, meta, hash)
+		if expected != "" && info.InfoHash != expected {
+			log.Printf("%s: info hash %s does not match expected %s", filename, info.InfoHash, expected)
+			mismatch = true
+		}
+		if *jsonOutput {
+			err = enc.Encode(info)
+			if err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		info.print()
+	}
+	if mismatch {
+		os.Exit(1)
+	}
+}
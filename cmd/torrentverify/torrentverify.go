@@ -0,0 +1,150 @@
+/*
+Command torrentverify checks local data against the piece hashes of a
+torrent.  It lists missing and corrupt files and exits with a nonzero status
+if the data is incomplete.
+
+	torrentverify [-d dir] [-q] <torrent>
+
+The torrent's content is expected in dir, which defaults to the working
+directory.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// Exit codes.
+const (
+	exitIncomplete = 1 // pieces are missing or corrupt
+	exitUsage      = 2 // invalid arguments or unreadable torrent
+)
+
+// dataFile is a file of a torrent's content on the local file system.
+type dataFile struct {
+	path    string // local path
+	name    string // path within the torrent
+	length  int64  // expected length
+	offset  int64  // offset within the torrent's content
+	size    int64  // actual length, or -1 if the file is missing
+	pad     bool   // a padding file, read as zeros without a local file
+	corrupt bool
+}
+
+// dataFiles returns the files of info with their local paths in dir.
+func dataFiles(info *metainfo.Info, dir string) []*dataFile {
+	if info.SingleFileMode() {
+		return []*dataFile{{
+			path:   filepath.Join(dir, info.Name),
+			name:   info.Name,
+			length: info.Length,
+		}}
+	}
+	var files []*dataFile
+	var offset int64
+	for _, file := range info.Files {
+		parts := append([]string{dir, info.Name}, file.Path...)
+		files = append(files, &dataFile{
+			path:   filepath.Join(parts...),
+			name:   filepath.Join(file.Path...),
+			length: file.Length,
+			offset: offset,
+			pad:    file.IsPadding(),
+		})
+		offset += file.Length
+	}
+	return files
+}
+
+// statFiles sets the size of each file, or -1 if the file is missing.
+func statFiles(files []*dataFile) {
+	for _, file := range files {
+		file.size = -1
+		if file.pad {
+			file.size = file.length
+			continue
+		}
+		stat, err := os.Stat(file.path)
+		if err == nil && !stat.IsDir() {
+			file.size = stat.Size()
+		}
+	}
+}
+
+// markCorrupt marks the files with data in bad piece i as corrupt.
+func markCorrupt(files []*dataFile, plen int64, i int) {
+	start := int64(i) * plen
+	end := start + plen
+	for _, file := range files {
+		if file.offset < end && file.offset+file.length > start && file.length > 0 {
+			file.corrupt = true
+		}
+	}
+}
+
+func main() {
+	dir := flag.String("d", ".", "directory containing the torrent's content")
+	quiet := flag.Bool("q", false, "do not report progress")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("torrentverify: ")
+	if flag.NArg() != 1 {
+		log.Printf("usage: %s [flags] <torrent>", os.Args[0])
+		os.Exit(exitUsage)
+	}
+	meta, err := metainfo.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Print(err)
+		os.Exit(exitUsage)
+	}
+
+	v, err := metainfo.NewDirVerifier(&meta.Info, *dir)
+	if err != nil {
+		log.Print(err)
+		os.Exit(exitUsage)
+	}
+	defer v.Close()
+	files := dataFiles(&meta.Info, *dir)
+	statFiles(files)
+	n := v.NumPieces()
+	var bad int
+	for i := 0; i < n; i++ {
+		ok, err := v.VerifyPiece(i)
+		if err != nil {
+			log.Print(err)
+		}
+		if !ok || err != nil {
+			bad++
+			markCorrupt(files, meta.Info.PieceLength, i)
+		}
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "\rpiece %d/%d (%d bad)", i+1, n, bad)
+		}
+	}
+	if !*quiet {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	for _, file := range files {
+		switch {
+		case file.pad:
+			// padding is not stored locally.
+		case file.size < 0:
+			fmt.Printf("missing: %s\n", file.name)
+		case file.size != file.length:
+			fmt.Printf("wrong size: %s (%d bytes, expected %d)\n", file.name, file.size, file.length)
+		case file.corrupt:
+			fmt.Printf("corrupt: %s\n", file.name)
+		}
+	}
+	fmt.Printf("%d of %d pieces ok\n", n-bad, n)
+	if bad > 0 {
+		os.Exit(exitIncomplete)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Command trackerannounce performs a single tracker announce and prints the
+response.  It is intended for debugging tracker compatibility.
+
+	trackerannounce [flags] <torrent|infohash> [tracker]
+
+The torrent may be given as a torrent file or a hex or base32 info hash.  If
+no tracker is given the torrent's first tracker is used.  The complete
+bencoded response of an HTTP tracker is printed after the parsed response.
+*/
+package main
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+	"github.com/bmatsuo/torrent/tracker"
+)
+
+// peerIDPrefix is the client identifier of generated peer ids.
+const peerIDPrefix = "-BT0001-"
+
+func randomPeerID() ([]byte, error) {
+	id := make([]byte, 20)
+	copy(id, peerIDPrefix)
+	_, err := rand.Read(id[len(peerIDPrefix):])
+	return id, err
+}
+
+// torrentArg returns the info hash, size, and first tracker of the torrent
+// named by arg.
+func torrentArg(arg string) (hash []byte, size int64, announce string, err error) {
+	if h, err := metainfo.ParseInfoHash(arg); err == nil {
+		return h[:], 0, "", nil
+	}
+	meta, h, err := metainfo.ReadFileWithHash(arg)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	hash = h[:]
+	size = meta.Info.Length
+	for _, file := range meta.Info.Files {
+		size += file.Length
+	}
+	announce = meta.Announce
+	if len(meta.AnnounceList) > 0 && len(meta.AnnounceList[0]) > 0 {
+		announce = meta.AnnounceList[0][0]
+	}
+	return hash, size, announce, nil
+}
+
+func main() {
+	event := flag.String("event", "started", "announce event (none, started, completed, stopped)")
+	port := flag.Int("port", 6881, "listening port to announce")
+	numwant := flag.Int("numwant", -1, "number of peers requested (negative for the tracker default)")
+	compact := flag.Bool("compact", true, "request compact peers")
+	uploaded := flag.Int64("uploaded", 0, "bytes uploaded")
+	downloaded := flag.Int64("downloaded", 0, "bytes downloaded")
+	left := flag.Int64("left", -1, "bytes left (default: the torrent's size, or 0 for an info hash)")
+	peerID := flag.String("peer-id", "", "20 byte peer id (default: random)")
+	timeout := flag.Duration("timeout", tracker.DefaultTimeout, "time limit for the announce")
+	network := flag.String("network", "", "network for udp trackers, udp4 or udp6 (default: automatic)")
+	ipv6 := flag.String("ipv6", "", "IPv6 address to announce to http trackers")
+	userAgent := flag.String("user-agent", "", "User-Agent header for http trackers")
+	insecure := flag.Bool("insecure", false, "do not verify the certificates of https trackers (for testing)")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("trackerannounce: ")
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		log.Fatalf("usage: %s [flags] <torrent|infohash> [tracker]", os.Args[0])
+	}
+
+	hash, size, announce, err := torrentArg(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if flag.NArg() > 1 {
+		announce = flag.Arg(1)
+	}
+	if announce == "" {
+		log.Fatal("no tracker")
+	}
+	req := &tracker.AnnounceRequest{
+		InfoHash:   hash,
+		Port:       *port,
+		Uploaded:   *uploaded,
+		Downloaded: *downloaded,
+		Left:       size,
+		NumWant:    *numwant,
+		Compact:    *compact,
+	}
+	if *left >= 0 {
+		req.Left = *left
+	}
+	req.Event, err = tracker.ParseEvent(*event)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ipv6 != "" {
+		req.IPv6 = net.ParseIP(*ipv6)
+		if req.IPv6 == nil || req.IPv6.To4() != nil {
+			log.Fatalf("invalid ipv6 address %q", *ipv6)
+		}
+	}
+	if *peerID != "" {
+		req.PeerID = []byte(*peerID)
+	} else {
+		req.PeerID, err = randomPeerID()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client := &tracker.Client{Timeout: *timeout, Network: *network, UserAgent: *userAgent}
+	if *insecure {
+		client.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	start := time.Now()
+	resp, err := client.Announce(announce, req)
+	if err, ok := err.(*tracker.Error); ok && err.Permanent {
+		log.Fatalf("%v (do not retry)", err)
+	} else if ok && err.RetryIn > 0 {
+		log.Fatalf("%v (retry in %v)", err, err.RetryIn)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("tracker:      %s (%v)\n", announce, time.Since(start))
+	fmt.Printf("interval:     %v\n", resp.Interval)
+	if resp.MinInterval > 0 {
+		fmt.Printf("min interval: %v\n", resp.MinInterval)
+	}
+	if resp.TrackerID != "" {
+		fmt.Printf("tracker id:   %q\n", resp.TrackerID)
+	}
+	if resp.Warning != "" {
+		fmt.Printf("warning:      %s\n", resp.Warning)
+	}
+	if resp.ExternalIP != nil {
+		fmt.Printf("external ip:  %v\n", resp.ExternalIP)
+	}
+	fmt.Printf("seeders:      %d\n", resp.Complete)
+	fmt.Printf("leechers:     %d\n", resp.Incomplete)
+	fmt.Printf("peers:        %d\n", len(resp.Peers))
+	for _, peer := range resp.Peers {
+		if peer.ID != nil {
+			fmt.Printf("  %s %q\n", peer, peer.ID)
+		} else {
+			fmt.Printf("  %s\n", peer)
+		}
+	}
+	if resp.Raw != nil {
+		var v interface{}
+		err = bencoding.Unmarshal(resp.Raw, &v)
+		if err == nil {
+			fmt.Println("response:")
+			err = bencoding.Dump(os.Stdout, v)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Command trackerscrape reports the seeders, leechers, and completed downloads
+of torrents from each of their trackers.
+
+	trackerscrape [-timeout 15s] [-json] <torrent> ...
+
+HTTP and UDP trackers are supported.  Trackers are scraped concurrently.
+The exit status is nonzero if any scrape fails.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/bmatsuo/torrent/metainfo"
+	"github.com/bmatsuo/torrent/tracker"
+)
+
+// torrentResult is the scrape result for a torrent.  It is the output format
+// of the -json flag.
+type torrentResult struct {
+	File     string          `json:"file"`
+	Name     string          `json:"name"`
+	InfoHash string          `json:"info_hash"`
+	Trackers []trackerResult `json:"trackers"`
+}
+
+type trackerResult struct {
+	URL       string `json:"url"`
+	Seeders   int64  `json:"seeders"`
+	Leechers  int64  `json:"leechers"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// trackers returns the unique trackers of meta in tier order.
+func trackers(meta *metainfo.Metainfo) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, tier := range meta.AnnounceList {
+		for _, u := range tier {
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	if len(urls) == 0 && meta.Announce != "" {
+		urls = append(urls, meta.Announce)
+	}
+	return urls
+}
+
+func scrape(client *tracker.Client, filename string) (*torrentResult, error) {
+	meta, hash, err := metainfo.ReadFileWithHash(filename)
+	if err != nil {
+		return nil, err
+	}
+	urls := trackers(meta)
+	r := &torrentResult{
+		File:     filename,
+		Name:     meta.Info.Name,
+		InfoHash: hash.Hex(),
+		Trackers: make([]trackerResult, len(urls)),
+	}
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(tr *trackerResult, u string) {
+			defer wg.Done()
+			tr.URL = u
+			results, err := client.Scrape(u, [][]byte{hash[:]})
+			switch {
+			case err != nil:
+				tr.Error = err.Error()
+			case results[0] == nil:
+				tr.Error = "torrent not reported by tracker"
+			default:
+				tr.Seeders = results[0].Complete
+				tr.Leechers = results[0].Incomplete
+				tr.Completed = results[0].Downloaded
+			}
+		}(&r.Trackers[i], u)
+	}
+	wg.Wait()
+	return r, nil
+}
+
+func (r *torrentResult) print() {
+	fmt.Printf("%s %s\n", r.InfoHash, r.Name)
+	if len(r.Trackers) == 0 {
+		fmt.Printf("  no trackers\n")
+	}
+	for _, tr := range r.Trackers {
+		if tr.Error != "" {
+			fmt.Printf("  %s: error: %s\n", tr.URL, tr.Error)
+			continue
+		}
+		fmt.Printf("  %s: %d seeders, %d leechers, %d completed\n",
+			tr.URL, tr.Seeders, tr.Leechers, tr.Completed)
+	}
+}
+
+func main() {
+	timeout := flag.Duration("timeout", tracker.DefaultTimeout, "time limit for each scrape")
+	jsonOutput := flag.Bool("json", false, "print results as a stream of JSON objects")
+	userAgent := flag.String("user-agent", "", "User-Agent header for http trackers")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("trackerscrape: ")
+	if flag.NArg() == 0 {
+		log.Fatalf("usage: %s [flags] <torrent> ...", os.Args[0])
+	}
+
+	client := &tracker.Client{Timeout: *timeout, UserAgent: *userAgent}
+	enc := json.NewEncoder(os.Stdout)
+	failed := false
+	for _, filename := range flag.Args() {
+		r, err := scrape(client, filename)
+		if err != nil {
+			log.Fatalf("%s: %v", filename, err)
+		}
+		for _, tr := range r.Trackers {
+			if tr.Error != "" {
+				failed = true
+			}
+		}
+		if *jsonOutput {
+			err = enc.Encode(r)
+			if err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+		r.print()
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Package fastresume reads and writes the resume data files of libtorrent, as
+used by qBittorrent, Deluge and other libtorrent based clients, so that the
+state of their torrents can be imported.
+
+Only the commonly used keys are represented.  Keys unknown to Data, such as
+saved peers and partial piece state, are kept in Data.Extra and written back
+unchanged.
+
+This package API is unstable and may change without notice.
+*/
+package fastresume
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// FileFormat identifies libtorrent resume files.
+const FileFormat = "libtorrent resume file"
+
+// Piece state flags stored in Data.Pieces.
+const (
+	PieceHave     = 1 << 0 // the piece is downloaded
+	PieceVerified = 1 << 1 // the piece hash has been checked
+)
+
+// Data is the content of a libtorrent resume file.
+type Data struct {
+	FileFormat        string `bencoding:"file-format"`
+	FileVersion       int64  `bencoding:"file-version"`
+	LibtorrentVersion string `bencoding:"libtorrent-version,omitempty"`
+
+	InfoHash []byte `bencoding:"info-hash"`
+	Name     string `bencoding:"name,omitempty"`
+	SavePath string `bencoding:"save_path"`
+
+	// Pieces has one byte of PieceHave and PieceVerified flags for each
+	// piece of the torrent.
+	Pieces []byte `bencoding:"pieces,omitempty"`
+
+	// FileSizes holds the size and modification time (in unix seconds) of
+	// each file when the resume data was saved.  Clients use it to detect
+	// files changed since.
+	FileSizes    [][2]int64 `bencoding:"file sizes,omitempty"`
+	FilePriority []int64    `bencoding:"file_priority,omitempty"`
+
+	Trackers [][]string `bencoding:"trackers,omitempty"`
+	URLList  []string   `bencoding:"url-list,omitempty"`
+
+	TotalUploaded   int64 `bencoding:"total_uploaded"`
+	TotalDownloaded int64 `bencoding:"total_downloaded"`
+	ActiveTime      int64 `bencoding:"active_time"`
+	SeedingTime     int64 `bencoding:"seeding_time"`
+	AddedTime       int64 `bencoding:"added_time,omitempty"`
+	CompletedTime   int64 `bencoding:"completed_time,omitempty"`
+
+	Paused      bool `bencoding:"paused"`
+	AutoManaged bool `bencoding:"auto_managed"`
+
+	// Info is the torrent's info dictionary, saved by clients for torrents
+	// added from magnet links.
+	Info bencoding.RawMessage `bencoding:"info,omitempty"`
+
+	// Extra holds the encoded values of keys unknown to Data.  They are
+	// encoded along with Data unless Data has a field for the key.
+	Extra map[string]bencoding.RawMessage `bencoding:"-"`
+}
+
+// data has the fields of Data without its encoding methods.
+type data Data
+
+// dataKeys is the set of keys with fields in Data.
+var dataKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	typ := reflect.TypeOf(Data{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("bencoding"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+// MarshalBencoding implements bencoding.Marshaller.  Keys in d.Extra are
+// merged into the encoding of d.
+func (d Data) MarshalBencoding() ([]byte, error) {
+	p, err := bencoding.Marshal(data(d))
+	if err != nil || len(d.Extra) == 0 {
+		return p, err
+	}
+	var m map[string]bencoding.RawMessage
+	err = bencoding.Unmarshal(p, &m)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range d.Extra {
+		if !dataKeys[k] {
+			m[k] = v
+		}
+	}
+	return bencoding.Marshal(m)
+}
+
+// UnmarshalBencoding implements bencoding.Unmarshaller.  Keys unknown to Data
+// are stored in d.Extra.
+func (d *Data) UnmarshalBencoding(p []byte) error {
+	var x data
+	err := bencoding.Unmarshal(p, &x)
+	if err != nil {
+		return err
+	}
+	var m map[string]bencoding.RawMessage
+	err = bencoding.Unmarshal(p, &m)
+	if err != nil {
+		return err
+	}
+	for k, v := range m {
+		if dataKeys[k] {
+			continue
+		}
+		if x.Extra == nil {
+			x.Extra = make(map[string]bencoding.RawMessage)
+		}
+		x.Extra[k] = v
+	}
+	*d = Data(x)
+	return nil
+}
+
+// New returns resume data for a torrent whose data is saved in savePath
+// and of which no pieces are downloaded.  The info hash is computed by
+// metainfo.Info.Hash, which is wrong for torrents decoded from files with
+// info keys unknown to Info; set InfoHash from metainfo.ReadFileWithHash
+// for those.
+func New(meta *metainfo.Metainfo, savePath string) (*Data, error) {
+	hash, err := meta.Info.Hash()
+	if err != nil {
+		return nil, err
+	}
+	d := &Data{
+		FileFormat:  FileFormat,
+		FileVersion: 1,
+		InfoHash:    hash[:],
+		Name:        meta.Info.Name,
+		SavePath:    savePath,
+		Pieces:      make([]byte, len(meta.Info.Pieces)/20),
+		Trackers:    meta.AnnounceList,
+		AddedTime:   time.Now().Unix(),
+		AutoManaged: true,
+	}
+	if len(d.Trackers) == 0 && meta.Announce != "" {
+		d.Trackers = [][]string{{meta.Announce}}
+	}
+	return d, nil
+}
+
+// Validate returns an error if d is not libtorrent resume data.
+func (d *Data) Validate() error {
+	if d.FileFormat != FileFormat {
+		return fmt.Errorf("not a libtorrent resume file: file-format %q", d.FileFormat)
+	}
+	if len(d.InfoHash) != 20 {
+		return fmt.Errorf("invalid info-hash length %d", len(d.InfoHash))
+	}
+	return nil
+}
+
+// Have returns true if piece i is downloaded.
+func (d *Data) Have(i int) bool {
+	return i >= 0 && i < len(d.Pieces) && d.Pieces[i]&PieceHave != 0
+}
+
+// SetHave marks piece i as downloaded and verified, or as missing.  Like
+// Have, SetHave ignores pieces out of range.
+func (d *Data) SetHave(i int, have bool) {
+	if i < 0 || i >= len(d.Pieces) {
+		return
+	}
+	if have {
+		d.Pieces[i] |= PieceHave | PieceVerified
+	} else {
+		d.Pieces[i] &^= PieceHave | PieceVerified
+	}
+}
+
+// Complete returns the number of downloaded pieces.
+func (d *Data) Complete() int {
+	n := 0
+	for i := range d.Pieces {
+		if d.Have(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// FileMTime returns the modification time of file i recorded in d, and
+// false if none was recorded.
+func (d *Data) FileMTime(i int) (time.Time, bool) {
+	if i < 0 || i >= len(d.FileSizes) || d.FileSizes[i][1] == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(d.FileSizes[i][1], 0), true
+}
+
+// ReadFile reads and validates a libtorrent resume file.
+func ReadFile(filename string) (*Data, error) {
+	p, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	d := new(Data)
+	err = bencoding.Unmarshal(p, d)
+	if err != nil {
+		return nil, err
+	}
+	err = d.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// WriteFile writes d to a libtorrent resume file.
+func WriteFile(filename string, d *Data, perm os.FileMode) error {
+	err := d.Validate()
+	if err != nil {
+		return err
+	}
+	p, err := bencoding.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, p, perm)
+}
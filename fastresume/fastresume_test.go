@@ -0,0 +1,132 @@
+package fastresume
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// testResume is resume data in the layout written by libtorrent 1.1,
+// including keys unknown to Data.
+var testResume = "d11:active_timei120e10:added_timei1500000000e12:auto_managedi1e" +
+	"10:file sizeslli5ei1499999999eeli0ei0eee" +
+	"11:file-format22:libtorrent resume file12:file-versioni1e" +
+	"9:info-hash20:01234567890123456789" +
+	"18:libtorrent-version7:1.1.5.0" +
+	"4:name4:test6:pausedi0e5:peers6:\x7f\x00\x00\x01\x1a\xe1" +
+	"6:pieces3:\x03\x00\x01" +
+	"9:save_path10:/downloads12:seeding_timei0e" +
+	"16:total_downloadedi10e14:total_uploadedi0e" +
+	"8:trackersll20:http://example.com/aeee"
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fastresume-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.fastresume")
+	err = ioutil.WriteFile(path, []byte(testResume), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.SavePath != "/downloads" || d.Name != "test" || d.ActiveTime != 120 || !d.AutoManaged {
+		t.Errorf("decoded %+v", d)
+	}
+	if !d.Have(0) || d.Have(1) || !d.Have(2) || d.Have(3) || d.Complete() != 2 {
+		t.Errorf("pieces %q", d.Pieces)
+	}
+	if mtime, ok := d.FileMTime(0); !ok || mtime.Unix() != 1499999999 {
+		t.Errorf("file 0 mtime %v", mtime)
+	}
+	if _, ok := d.FileMTime(1); ok {
+		t.Errorf("file 1 has an mtime")
+	}
+
+	if string(d.Extra["peers"]) != "6:\x7f\x00\x00\x01\x1a\xe1" || len(d.Extra) != 1 {
+		t.Errorf("extra %q", d.Extra)
+	}
+
+	err = WriteFile(path, d, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != testResume {
+		t.Errorf("wrote %q (expected %q)", p, testResume)
+	}
+	d2, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, d2) {
+		t.Errorf("read %+v (expected %+v)", d2, d)
+	}
+
+	err = ioutil.WriteFile(path, []byte("d11:file-format4:xxxxe"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadFile(path)
+	if err == nil {
+		t.Errorf("read a file which is not resume data")
+	}
+}
+
+func TestNew(t *testing.T) {
+	meta := &metainfo.Metainfo{
+		Info: metainfo.Info{
+			Name:        "a",
+			Length:      40,
+			PieceLength: 16,
+			Pieces:      bytes.Repeat([]byte{1}, 60),
+		},
+		Announce: "http://example.com/a",
+	}
+	d, err := New(meta, "/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := meta.Info.Hash()
+	if !bytes.Equal(d.InfoHash, hash[:]) || len(d.Pieces) != 3 || d.Complete() != 0 {
+		t.Errorf("resume data %+v", d)
+	}
+	d.SetHave(1, true)
+	if !d.Have(1) || d.Pieces[1] != PieceHave|PieceVerified {
+		t.Errorf("pieces %q", d.Pieces)
+	}
+	d.SetHave(1, false)
+	if d.Have(1) {
+		t.Errorf("pieces %q", d.Pieces)
+	}
+	d.SetHave(-1, true)
+	d.SetHave(3, true)
+	if d.Complete() != 0 {
+		t.Errorf("pieces %q", d.Pieces)
+	}
+	p, err := bencoding.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	err = bencoding.Unmarshal(p, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v["file-format"] != FileFormat || !reflect.DeepEqual(v["trackers"], []interface{}{[]interface{}{"http://example.com/a"}}) {
+		t.Errorf("encoded %q", p)
+	}
+}
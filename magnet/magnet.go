@@ -0,0 +1,122 @@
+/*
+Package magnet parses and generates magnet links (BEP 9).
+
+	magnet:?xt=urn:btih:<info hash>&dn=<name>&tr=<tracker>&ws=<web seed>
+
+Links identify a torrent by the SHA-1 hash of its info dictionary (btih) or,
+for BitTorrent v2 torrents, by a SHA-256 multihash (btmh).
+
+This package API is unstable and may change without notice.
+*/
+package magnet
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// sha256 multihash prefix for btmh exact topics.
+const multihashSHA256 = "1220"
+
+// Link is a parsed magnet link.  A Link must have an InfoHash, an InfoHashV2,
+// or both.
+type Link struct {
+	InfoHash   []byte   // SHA-1 info hash (xt=urn:btih)
+	InfoHashV2 []byte   // SHA-256 info hash (xt=urn:btmh)
+	Name       string   // display name (dn)
+	Trackers   []string // tracker URLs (tr)
+	WebSeeds   []string // web seed URLs (ws)
+}
+
+// Parse parses a magnet link.  Unrecognized parameters are ignored.
+func Parse(link string) (*Link, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "magnet" {
+		return nil, fmt.Errorf("not a magnet link")
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+	m := &Link{
+		Name:     q.Get("dn"),
+		Trackers: q["tr"],
+		WebSeeds: q["ws"],
+	}
+	for _, xt := range q["xt"] {
+		switch {
+		case strings.HasPrefix(xt, "urn:btih:"):
+			m.InfoHash, err = parseBTIH(strings.TrimPrefix(xt, "urn:btih:"))
+		case strings.HasPrefix(xt, "urn:btmh:"):
+			m.InfoHashV2, err = parseBTMH(strings.TrimPrefix(xt, "urn:btmh:"))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if m.InfoHash == nil && m.InfoHashV2 == nil {
+		return nil, fmt.Errorf("no btih or btmh exact topic")
+	}
+	return m, nil
+}
+
+// parseBTIH parses a hex or base32 encoded SHA-1 info hash.
+func parseBTIH(s string) ([]byte, error) {
+	switch len(s) {
+	case 40:
+		return hex.DecodeString(s)
+	case 32:
+		return base32.StdEncoding.DecodeString(strings.ToUpper(s))
+	}
+	return nil, fmt.Errorf("invalid btih %q", s)
+}
+
+// parseBTMH parses a hex encoded SHA-256 multihash.
+func parseBTMH(s string) ([]byte, error) {
+	if len(s) != len(multihashSHA256)+64 || !strings.HasPrefix(s, multihashSHA256) {
+		return nil, fmt.Errorf("unsupported btmh %q", s)
+	}
+	return hex.DecodeString(s[len(multihashSHA256):])
+}
+
+// String returns the magnet link for m.  Info hashes are hex encoded.
+func (m *Link) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("magnet:?")
+	// url.Values.Encode escapes the ':' characters in xt, which many
+	// clients do not accept, and sorts keys, which moves xt after dn.
+	add := func(key, val string, escape bool) {
+		if buf.Len() > len("magnet:?") {
+			buf.WriteByte('&')
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		if escape {
+			val = url.QueryEscape(val)
+		}
+		buf.WriteString(val)
+	}
+	if m.InfoHash != nil {
+		add("xt", "urn:btih:"+hex.EncodeToString(m.InfoHash), false)
+	}
+	if m.InfoHashV2 != nil {
+		add("xt", "urn:btmh:"+multihashSHA256+hex.EncodeToString(m.InfoHashV2), false)
+	}
+	if m.Name != "" {
+		add("dn", m.Name, true)
+	}
+	for _, tr := range m.Trackers {
+		add("tr", tr, true)
+	}
+	for _, ws := range m.WebSeeds {
+		add("ws", ws, true)
+	}
+	return buf.String()
+}
@@ -0,0 +1,105 @@
+package magnet
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testHash = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+
+func TestParse(t *testing.T) {
+	for i, test := range []struct {
+		link string
+		name string
+		tr   []string
+		ws   []string
+	}{
+		{"magnet:?xt=urn:btih:" + testHash, "", nil, nil},
+		{"magnet:?xt=urn:btih:" + strings.ToUpper(testHash), "", nil, nil},
+		{"magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK", "", nil, nil},
+		{"magnet:?xt=urn:btih:yex6dqdlxisuvhoj6um3gnnkpqjwpkek", "", nil, nil},
+		{
+			"magnet:?xt=urn:btih:" + testHash + "&dn=a+b&tr=http%3A%2F%2Ft1%2Fannounce&tr=udp://t2:80&ws=http://s/f",
+			"a b",
+			[]string{"http://t1/announce", "udp://t2:80"},
+			[]string{"http://s/f"},
+		},
+	} {
+		m, err := Parse(test.link)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if h := hex.EncodeToString(m.InfoHash); h != testHash {
+			t.Errorf("test %d: info hash %s (!= %s)", i, h, testHash)
+		}
+		if m.InfoHashV2 != nil {
+			t.Errorf("test %d: unexpected btmh %x", i, m.InfoHashV2)
+		}
+		if m.Name != test.name {
+			t.Errorf("test %d: name %q (!= %q)", i, m.Name, test.name)
+		}
+		if !reflect.DeepEqual(m.Trackers, test.tr) {
+			t.Errorf("test %d: trackers %q (!= %q)", i, m.Trackers, test.tr)
+		}
+		if !reflect.DeepEqual(m.WebSeeds, test.ws) {
+			t.Errorf("test %d: web seeds %q (!= %q)", i, m.WebSeeds, test.ws)
+		}
+	}
+}
+
+func TestParseV2(t *testing.T) {
+	v2 := strings.Repeat("ab", 32)
+	m, err := Parse("magnet:?xt=urn:btih:" + testHash + "&xt=urn:btmh:1220" + v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := hex.EncodeToString(m.InfoHashV2); h != v2 {
+		t.Errorf("btmh %s (!= %s)", h, v2)
+	}
+	if h := hex.EncodeToString(m.InfoHash); h != testHash {
+		t.Errorf("btih %s (!= %s)", h, testHash)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for i, link := range []string{
+		"http://example.com/?xt=urn:btih:" + testHash,
+		"magnet:?dn=name",
+		"magnet:?xt=urn:sha1:" + testHash,
+		"magnet:?xt=urn:btih:abc",
+		"magnet:?xt=urn:btih:" + strings.Repeat("zz", 20),
+		"magnet:?xt=urn:btmh:1114" + strings.Repeat("ab", 32),
+		"magnet:?xt=urn:btih:" + testHash + "&dn=%zz",
+	} {
+		m, err := Parse(link)
+		if err == nil {
+			t.Errorf("test %d: expected error (got %v)", i, m)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	h, _ := hex.DecodeString(testHash)
+	m := &Link{
+		InfoHash: h,
+		Name:     "a b&c",
+		Trackers: []string{"http://t1/announce?k=v"},
+		WebSeeds: []string{"http://s/f"},
+	}
+	link := m.String()
+	expect := "magnet:?xt=urn:btih:" + testHash +
+		"&dn=a+b%26c&tr=http%3A%2F%2Ft1%2Fannounce%3Fk%3Dv&ws=http%3A%2F%2Fs%2Ff"
+	if link != expect {
+		t.Errorf("link %s (!= %s)", link, expect)
+	}
+	m2, err := Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m2, m) {
+		t.Errorf("round trip %#v (!= %#v)", m2, m)
+	}
+}
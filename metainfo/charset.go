@@ -0,0 +1,140 @@
+package metainfo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Charset converts text between a character encoding and UTF-8.  Charsets
+// such as Shift_JIS or GBK can be provided by packages like
+// golang.org/x/text and registered with RegisterCharset.
+type Charset interface {
+	// Decode converts p from the charset to UTF-8.
+	Decode(p []byte) (string, error)
+	// Encode converts the UTF-8 string s to the charset.
+	Encode(s string) ([]byte, error)
+}
+
+var charsets = struct {
+	sync.RWMutex
+	m map[string]Charset
+}{m: map[string]Charset{
+	"utf-8":      utf8Charset{},
+	"utf8":       utf8Charset{},
+	"iso-8859-1": latin1Charset{},
+	"latin1":     latin1Charset{},
+}}
+
+// RegisterCharset makes c available under name, which is matched against
+// Metainfo.Encoding without regard to case.
+func RegisterCharset(name string, c Charset) {
+	charsets.Lock()
+	defer charsets.Unlock()
+	charsets.m[strings.ToLower(name)] = c
+}
+
+// LookupCharset returns the charset registered under name.  UTF-8 and
+// ISO-8859-1 are always available.
+func LookupCharset(name string) (Charset, bool) {
+	charsets.RLock()
+	defer charsets.RUnlock()
+	c, ok := charsets.m[strings.ToLower(name)]
+	return c, ok
+}
+
+type utf8Charset struct{}
+
+func (utf8Charset) Decode(p []byte) (string, error) {
+	if !utf8.Valid(p) {
+		return "", fmt.Errorf("invalid utf-8")
+	}
+	return string(p), nil
+}
+
+func (utf8Charset) Encode(s string) ([]byte, error) {
+	return []byte(s), nil
+}
+
+type latin1Charset struct{}
+
+func (latin1Charset) Decode(p []byte) (string, error) {
+	r := make([]rune, len(p))
+	for i, c := range p {
+		r[i] = rune(c)
+	}
+	return string(r), nil
+}
+
+func (latin1Charset) Encode(s string) ([]byte, error) {
+	p := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, fmt.Errorf("%q cannot be encoded as latin1", r)
+		}
+		p = append(p, byte(r))
+	}
+	return p, nil
+}
+
+// UTF8Names returns a copy of meta with the torrent name, file paths,
+// comment, and creator converted from meta.Encoding to UTF-8, and Encoding
+// set to UTF-8.  An empty Encoding is taken to be UTF-8.  The copy is meant
+// for display and extracting files; its info hash differs from the
+// original's unless the names were already UTF-8.
+func (meta *Metainfo) UTF8Names() (*Metainfo, error) {
+	enc := meta.Encoding
+	if enc == "" {
+		enc = "UTF-8"
+	}
+	c, ok := LookupCharset(enc)
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", meta.Encoding)
+	}
+	decode := func(s string) (string, error) { return c.Decode([]byte(s)) }
+	return meta.convertNames(decode, "UTF-8")
+}
+
+// EncodeNames returns a copy of meta, whose names are UTF-8, with names
+// converted to the named charset and Encoding set to name.  It reverses
+// UTF8Names.
+func (meta *Metainfo) EncodeNames(name string) (*Metainfo, error) {
+	c, ok := LookupCharset(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	encode := func(s string) (string, error) {
+		p, err := c.Encode(s)
+		return string(p), err
+	}
+	return meta.convertNames(encode, name)
+}
+
+func (meta *Metainfo) convertNames(conv func(string) (string, error), enc string) (*Metainfo, error) {
+	out := *meta
+	out.Info = meta.Info.Clone()
+	out.Encoding = enc
+	var err error
+	convert := func(s *string) {
+		if err == nil {
+			*s, err = conv(*s)
+		}
+	}
+	convert(&out.Info.Name)
+	for i := range out.Info.Files {
+		file := &out.Info.Files[i]
+		for j := range file.Path {
+			convert(&file.Path[j])
+		}
+		for j := range file.SymlinkPath {
+			convert(&file.SymlinkPath[j])
+		}
+	}
+	convert(&out.Comment)
+	convert(&out.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
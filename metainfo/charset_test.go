@@ -0,0 +1,60 @@
+package metainfo
+
+import (
+	"strings"
+	"testing"
+)
+
+// upperCharset is a toy charset storing text in upper case.
+type upperCharset struct{}
+
+func (upperCharset) Decode(p []byte) (string, error) { return strings.ToLower(string(p)), nil }
+func (upperCharset) Encode(s string) ([]byte, error) { return []byte(strings.ToUpper(s)), nil }
+
+func TestUTF8Names(t *testing.T) {
+	meta := &Metainfo{
+		Encoding: "ISO-8859-1",
+		Comment:  "caf\xe9",
+		Info: Info{
+			Name: "na\xefve",
+			Files: []FileInfo{
+				{Path: []string{"d\xe9j\xe0", "vu"}, Length: 1},
+			},
+		},
+	}
+	utf, err := meta.UTF8Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if utf.Info.Name != "naïve" || utf.Comment != "café" || utf.Info.Files[0].Path[0] != "déjà" {
+		t.Errorf("converted names %q %q %q", utf.Info.Name, utf.Comment, utf.Info.Files[0].Path)
+	}
+	if utf.Encoding != "UTF-8" {
+		t.Errorf("encoding %q", utf.Encoding)
+	}
+	if meta.Info.Files[0].Path[0] != "d\xe9j\xe0" {
+		t.Errorf("original modified")
+	}
+	back, err := utf.EncodeNames("latin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Info.Name != meta.Info.Name || back.Info.Files[0].Path[0] != meta.Info.Files[0].Path[0] {
+		t.Errorf("round trip names %q %q", back.Info.Name, back.Info.Files[0].Path)
+	}
+	if _, err := (&Metainfo{Info: Info{Name: "日本"}}).EncodeNames("latin1"); err == nil {
+		t.Errorf("encoded non-latin1 name")
+	}
+	if _, err := (&Metainfo{Encoding: "x-unknown"}).UTF8Names(); err == nil {
+		t.Errorf("converted from an unknown encoding")
+	}
+	if _, err := (&Metainfo{Info: Info{Name: "\xff"}}).UTF8Names(); err == nil {
+		t.Errorf("accepted invalid utf-8")
+	}
+
+	RegisterCharset("X-Upper", upperCharset{})
+	utf, err = (&Metainfo{Encoding: "x-upper", Info: Info{Name: "ABC"}}).UTF8Names()
+	if err != nil || utf.Info.Name != "abc" {
+		t.Errorf("registered charset: %q %v", utf.Info.Name, err)
+	}
+}
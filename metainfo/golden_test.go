@@ -0,0 +1,62 @@
+package metainfo_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+	"github.com/bmatsuo/torrent/torrenttest"
+)
+
+// TestGolden checks that the golden torrents decode to the same Metainfo
+// after a round trip and that their encoding is stable.
+func TestGolden(t *testing.T) {
+	for _, golden := range torrenttest.LoadGolden(t, filepath.Join("test", "torrents", "*.torrent")) {
+		p, err := bencoding.Marshal(golden.Meta)
+		if err != nil {
+			t.Errorf("unable to marshal metainfo for %q: %v", golden.Name, err)
+			continue
+		}
+		var meta metainfo.Metainfo
+		err = bencoding.Unmarshal(p, &meta)
+		if err != nil {
+			t.Errorf("unable to parse marshalled output for %q: %v", golden.Name, err)
+			continue
+		}
+		torrenttest.CompareMetainfo(t, golden.Name, &meta, golden.Meta)
+		cpp, err := bencoding.Marshal(meta)
+		if err != nil {
+			t.Errorf("unable to marshal metainfo for %q: %v", golden.Name, err)
+			continue
+		}
+		if !bytes.Equal(p, cpp) {
+			t.Errorf("unstable serialization output for %q", golden.Name)
+		}
+	}
+}
+
+func TestOpenMapped(t *testing.T) {
+	for _, golden := range torrenttest.LoadGolden(t, filepath.Join("test", "torrents", "*.torrent")) {
+		f, err := metainfo.OpenMapped(golden.Path)
+		if err != nil {
+			t.Errorf("%s: %v", golden.Name, err)
+			continue
+		}
+		torrenttest.CompareMetainfo(t, golden.Name, f.Meta, golden.Meta)
+		info := f.Meta.Info.Clone()
+		err = f.Close()
+		if err != nil {
+			t.Errorf("%s: close: %v", golden.Name, err)
+		}
+		// the clone does not refer to the mapping.
+		info.Pieces[0]++
+		if info.Pieces[0] != golden.Meta.Info.Pieces[0]+1 {
+			t.Errorf("%s: clone pieces %x", golden.Name, info.Pieces[:1])
+		}
+		if f.Close() == nil {
+			t.Errorf("%s: second close succeeded", golden.Name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package metainfo
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// InfoHash is the SHA-1 hash of a torrent's info dictionary.  InfoHash
+// values are comparable with ==.
+type InfoHash [20]byte
+
+// ParseInfoHash parses an info hash encoded in hex (40 characters) or
+// base32 (32 characters), as found in magnet links.  Both encodings are
+// case-insensitive.
+func ParseInfoHash(s string) (InfoHash, error) {
+	var h InfoHash
+	var p []byte
+	var err error
+	switch len(s) {
+	case 2 * len(h):
+		p, err = hex.DecodeString(s)
+	case base32.StdEncoding.EncodedLen(len(h)):
+		p, err = base32.StdEncoding.DecodeString(strings.ToUpper(s))
+	default:
+		return h, fmt.Errorf("invalid info hash length %d", len(s))
+	}
+	if err != nil {
+		return h, fmt.Errorf("invalid info hash %q", s)
+	}
+	copy(h[:], p)
+	return h, nil
+}
+
+// Hex returns the lower case hex encoding of h.
+func (h InfoHash) Hex() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Base32 returns the base32 encoding of h.
+func (h InfoHash) Base32() string {
+	return base32.StdEncoding.EncodeToString(h[:])
+}
+
+// String returns the hex encoding of h.
+func (h InfoHash) String() string {
+	return h.Hex()
+}
+
+// MarshalText implements encoding.TextMarshaler using the hex encoding.
+func (h InfoHash) MarshalText() ([]byte, error) {
+	return []byte(h.Hex()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.  Hex and base32
+// encodings are accepted.
+func (h *InfoHash) UnmarshalText(p []byte) error {
+	x, err := ParseInfoHash(string(p))
+	if err != nil {
+		return err
+	}
+	*h = x
+	return nil
+}
@@ -0,0 +1,56 @@
+package metainfo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseInfoHash(t *testing.T) {
+	const hex = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	const b32 = "YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK"
+	for _, s := range []string{hex, strings.ToUpper(hex), b32, strings.ToLower(b32)} {
+		h, err := ParseInfoHash(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if h.Hex() != hex {
+			t.Errorf("%s: hex %s (!= %s)", s, h.Hex(), hex)
+		}
+		if h.Base32() != b32 {
+			t.Errorf("%s: base32 %s (!= %s)", s, h.Base32(), b32)
+		}
+		if h.String() != hex {
+			t.Errorf("%s: string %s (!= %s)", s, h, hex)
+		}
+	}
+	for _, s := range []string{"", hex[:39], hex + "0", "z" + hex[1:], "1" + b32[1:]} {
+		_, err := ParseInfoHash(s)
+		if err == nil {
+			t.Errorf("%q: parsed an invalid info hash", s)
+		}
+	}
+}
+
+func TestInfoHash_JSON(t *testing.T) {
+	h, err := ParseInfoHash("c12fe1c06bba254a9dc9f519b335aa7c1367a88a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != `"c12fe1c06bba254a9dc9f519b335aa7c1367a88a"` {
+		t.Errorf("json %s", p)
+	}
+	var h2 InfoHash
+	err = json.Unmarshal(p, &h2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h2 != h {
+		t.Errorf("decoded %v (!= %v)", h2, h)
+	}
+}
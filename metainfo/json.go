@@ -0,0 +1,198 @@
+package metainfo
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// jsonFileInfo is the JSON representation of a FileInfo.
+type jsonFileInfo struct {
+	Path        []string `json:"path"`
+	Length      int64    `json:"length"`
+	MD5Sum      string   `json:"md5sum,omitempty"`
+	Attr        string   `json:"attr,omitempty"`
+	SymlinkPath []string `json:"symlink_path,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (file FileInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonFileInfo(file))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (file *FileInfo) UnmarshalJSON(p []byte) error {
+	var v jsonFileInfo
+	err := json.Unmarshal(p, &v)
+	if err != nil {
+		return err
+	}
+	*file = FileInfo(v)
+	return nil
+}
+
+// jsonInfo is the JSON representation of an Info.  Binary values are hex
+// encoded.
+type jsonInfo struct {
+	InfoHash    string     `json:"info_hash,omitempty"`
+	Name        string     `json:"name"`
+	Files       []FileInfo `json:"files,omitempty"`
+	Length      int64      `json:"length,omitempty"`
+	MD5Sum      string     `json:"md5sum,omitempty"`
+	PieceLength int64      `json:"piece_length"`
+	Pieces      string     `json:"pieces"`
+	Private     bool       `json:"private,omitempty"`
+	Source      string     `json:"source,omitempty"`
+	RootHash    string     `json:"root_hash,omitempty"`
+	Similar     []string   `json:"similar,omitempty"`
+	Collections []string   `json:"collections,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.  Pieces and other hashes are
+// encoded in hex, and the info hash is included for convenience.  The info
+// hash is computed by Hash and has the same limitation for torrents with
+// keys unknown to Info.
+func (info Info) MarshalJSON() ([]byte, error) {
+	hash, err := info.Hash()
+	if err != nil {
+		return nil, err
+	}
+	v := jsonInfo{
+		InfoHash:    hash.Hex(),
+		Name:        info.Name,
+		Files:       info.Files,
+		Length:      info.Length,
+		MD5Sum:      info.MD5Sum,
+		PieceLength: info.PieceLength,
+		Pieces:      hex.EncodeToString(info.Pieces),
+		Private:     info.Private,
+		Source:      info.Source,
+		RootHash:    hex.EncodeToString(info.RootHash),
+		Collections: info.Collections,
+	}
+	for _, h := range info.Similar {
+		v.Similar = append(v.Similar, h.Hex())
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.  The info hash is ignored, as
+// it is computed from the other fields.
+func (info *Info) UnmarshalJSON(p []byte) error {
+	var v jsonInfo
+	err := json.Unmarshal(p, &v)
+	if err != nil {
+		return err
+	}
+	x := Info{
+		Name:        v.Name,
+		Files:       v.Files,
+		Length:      v.Length,
+		MD5Sum:      v.MD5Sum,
+		PieceLength: v.PieceLength,
+		Private:     v.Private,
+		Source:      v.Source,
+		Collections: v.Collections,
+	}
+	x.Pieces, err = hex.DecodeString(v.Pieces)
+	if err != nil {
+		return fmt.Errorf("pieces: %v", err)
+	}
+	if v.RootHash != "" {
+		x.RootHash, err = hex.DecodeString(v.RootHash)
+		if err != nil {
+			return fmt.Errorf("root hash: %v", err)
+		}
+	}
+	for _, s := range v.Similar {
+		h, err := ParseInfoHash(s)
+		if err != nil {
+			return fmt.Errorf("similar: %v", err)
+		}
+		x.Similar = append(x.Similar, h)
+	}
+	*info = x
+	return nil
+}
+
+// MarshalText encodes node as "host:port".
+func (node Node) MarshalText() ([]byte, error) {
+	return []byte(node.String()), nil
+}
+
+// UnmarshalText decodes a node from "host:port".
+func (node *Node) UnmarshalText(p []byte) error {
+	host, port, err := net.SplitHostPort(string(p))
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid node port %q", port)
+	}
+	node.Host, node.Port = host, n
+	return nil
+}
+
+// jsonMetainfo is the JSON representation of a Metainfo.
+type jsonMetainfo struct {
+	Info         Info       `json:"info"`
+	Announce     string     `json:"announce,omitempty"`
+	AnnounceList [][]string `json:"announce_list,omitempty"`
+	Nodes        []Node     `json:"nodes,omitempty"`
+	URLList      []string   `json:"url_list,omitempty"`
+	CreationDate string     `json:"creation_date,omitempty"`
+	Encoding     string     `json:"encoding,omitempty"`
+	CreatedBy    string     `json:"created_by,omitempty"`
+	Comment      string     `json:"comment,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.  The creation date is encoded in
+// RFC 3339 format.
+func (meta Metainfo) MarshalJSON() ([]byte, error) {
+	v := jsonMetainfo{
+		Info:         meta.Info,
+		Announce:     meta.Announce,
+		AnnounceList: meta.AnnounceList,
+		Nodes:        meta.Nodes,
+		URLList:      meta.URLList,
+		Encoding:     meta.Encoding,
+		CreatedBy:    meta.CreatedBy,
+		Comment:      meta.Comment,
+	}
+	if meta.CreationDate != 0 {
+		v.CreationDate = time.Unix(meta.CreationDate, 0).UTC().Format(time.RFC3339)
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (meta *Metainfo) UnmarshalJSON(p []byte) error {
+	var v jsonMetainfo
+	err := json.Unmarshal(p, &v)
+	if err != nil {
+		return err
+	}
+	x := Metainfo{
+		Info:         v.Info,
+		Announce:     v.Announce,
+		AnnounceList: v.AnnounceList,
+		Nodes:        v.Nodes,
+		URLList:      v.URLList,
+		Encoding:     v.Encoding,
+		CreatedBy:    v.CreatedBy,
+		Comment:      v.Comment,
+	}
+	if v.CreationDate != "" {
+		t, err := time.Parse(time.RFC3339, v.CreationDate)
+		if err != nil {
+			return fmt.Errorf("creation date: %v", err)
+		}
+		x.CreationDate = t.Unix()
+	}
+	*meta = x
+	return nil
+}
@@ -0,0 +1,66 @@
+package metainfo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMetainfo_MarshalJSON(t *testing.T) {
+	meta := &Metainfo{
+		Info: Info{
+			Name:        "a",
+			Length:      5,
+			Pieces:      []byte{0xab, 0xcd},
+			PieceLength: 16,
+		},
+		Announce:     "http://example.com",
+		Nodes:        []Node{{"::1", 6881}},
+		CreationDate: 1e9,
+	}
+	p, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	err = json.Unmarshal(p, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := v["info"].(map[string]interface{})
+	hash, err := meta.Info.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		key    string
+		val    interface{}
+		expect interface{}
+	}{
+		{"creation_date", v["creation_date"], "2001-09-09T01:46:40Z"},
+		{"nodes", v["nodes"], []interface{}{"[::1]:6881"}},
+		{"info.pieces", info["pieces"], "abcd"},
+		{"info.piece_length", info["piece_length"], 16.0},
+		{"info.info_hash", info["info_hash"], hash.Hex()},
+	} {
+		if !jsonEqual(test.val, test.expect) {
+			t.Errorf("%s: %v (expected %v)", test.key, test.val, test.expect)
+		}
+	}
+	for _, bad := range []string{
+		`{"info":{"pieces":"xyz"}}`,
+		`{"creation_date":"yesterday"}`,
+		`{"nodes":["localhost"]}`,
+	} {
+		var meta Metainfo
+		err := json.Unmarshal([]byte(bad), &meta)
+		if err == nil {
+			t.Errorf("decoded %s", bad)
+		}
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	pa, _ := json.Marshal(a)
+	pb, _ := json.Marshal(b)
+	return string(pa) == string(pb)
+}
@@ -0,0 +1,69 @@
+package metainfo
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bmatsuo/torrent/bencoding"
+)
+
+// MappedFile is a metainfo file decoded from memory-mapped content.  Byte
+// slices in Meta, notably Info.Pieces, refer to the mapping instead of being
+// copied, so only the pages a caller touches become resident.  The mapping is
+// read-only: writing to the slices, or reading them after the MappedFile is
+// closed, crashes the program rather than returning an error.  Use
+// Info.Clone to obtain a copy that can be modified or kept after Close.
+type MappedFile struct {
+	Meta *Metainfo
+	data []byte
+}
+
+// OpenMapped memory-maps the (.torrent) metainfo file filename and decodes
+// it.  On platforms without mmap support the file is read into memory.  The
+// returned MappedFile must be closed to release the mapping.
+func OpenMapped(filename string) (*MappedFile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+	if int64(int(stat.Size())) != stat.Size() {
+		return nil, fmt.Errorf("file too large")
+	}
+	data, err := mmapFile(f, int(stat.Size()))
+	if err != nil {
+		return nil, err
+	}
+	meta := new(Metainfo)
+	dec := bencoding.NewDecoderBytes(data)
+	dec.UseZeroCopy()
+	err = dec.Decode(meta)
+	if err == nil {
+		var rest interface{}
+		if dec.Decode(&rest) != bencoding.EOF {
+			err = fmt.Errorf("trailing bytes")
+		}
+	}
+	if err != nil {
+		munmapFile(data)
+		return nil, err
+	}
+	return &MappedFile{meta, data}, nil
+}
+
+// Close releases the mapping backing f.Meta.
+func (f *MappedFile) Close() error {
+	if f.data == nil {
+		return errClosed
+	}
+	data := f.data
+	f.data = nil
+	return munmapFile(data)
+}
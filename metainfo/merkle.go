@@ -0,0 +1,85 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+)
+
+// Merkle torrents (BEP 30) replace the piece hashes of an Info with the root
+// of a binary hash tree.  The leaves of the tree are the piece hashes, padded
+// to a power of two with zero hashes.  Each interior node is the SHA-1 hash of
+// its children concatenated.
+// http://www.bittorrent.org/beps/bep_0030.html
+
+// merkleLevels returns the levels of the hash tree over pieces, leaves first.
+// The last level holds the root.
+func merkleLevels(pieces []byte) [][][]byte {
+	n := len(pieces) / sha1.Size
+	width := 1
+	for width < n {
+		width *= 2
+	}
+	level := make([][]byte, width)
+	for i := range level {
+		if i < n {
+			level[i] = pieces[i*sha1.Size : (i+1)*sha1.Size]
+		} else {
+			level[i] = make([]byte, sha1.Size)
+		}
+	}
+	levels := [][][]byte{level}
+	for len(level) > 1 {
+		next := make([][]byte, len(level)/2)
+		for i := range next {
+			next[i] = merkleNode(level[2*i], level[2*i+1])
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+func merkleNode(left, right []byte) []byte {
+	h := sha1.New()
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// MerkleRoot returns the root hash of the tree whose leaves are the
+// concatenated piece hashes in pieces.
+func MerkleRoot(pieces []byte) []byte {
+	levels := merkleLevels(pieces)
+	return append([]byte(nil), levels[len(levels)-1][0]...)
+}
+
+// MerkleProof returns the hashes a seeder sends with piece i so that a peer
+// knowing only the root hash can verify it.  The hashes are the siblings of
+// the piece's ancestors, ordered from the leaf level upward.  MerkleProof
+// panics if i is not the index of a piece.
+func MerkleProof(pieces []byte, i int) [][]byte {
+	if i < 0 || i >= len(pieces)/sha1.Size {
+		panic("piece index out of range")
+	}
+	levels := merkleLevels(pieces)
+	var proof [][]byte
+	for _, level := range levels[:len(levels)-1] {
+		proof = append(proof, level[i^1])
+		i /= 2
+	}
+	return proof
+}
+
+// VerifyMerkleProof returns true if hash is the hash of piece i given the root
+// hash of the tree and the proof produced by MerkleProof.
+func VerifyMerkleProof(root, hash []byte, i int, proof [][]byte) bool {
+	for _, sibling := range proof {
+		if i%2 == 0 {
+			hash = merkleNode(hash, sibling)
+		} else {
+			hash = merkleNode(sibling, hash)
+		}
+		i /= 2
+	}
+	return i == 0 && bytes.Equal(hash, root)
+}
@@ -0,0 +1,89 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/bmatsuo/torrent/bencoding"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	pieces := make([]byte, 3*sha1.Size)
+	for i := range pieces {
+		pieces[i] = byte(i)
+	}
+	zero := make([]byte, sha1.Size)
+	expect := merkleNode(
+		merkleNode(pieces[:20], pieces[20:40]),
+		merkleNode(pieces[40:], zero))
+	root := MerkleRoot(pieces)
+	if !bytes.Equal(root, expect) {
+		t.Errorf("root %x (expected %x)", root, expect)
+	}
+	if root := MerkleRoot(pieces[:20]); !bytes.Equal(root, pieces[:20]) {
+		t.Errorf("single piece root %x (expected the piece hash)", root)
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 8, 13} {
+		pieces := make([]byte, n*sha1.Size)
+		for i := range pieces {
+			pieces[i] = byte(i * 3)
+		}
+		root := MerkleRoot(pieces)
+		for i := 0; i < n; i++ {
+			hash := pieces[i*sha1.Size : (i+1)*sha1.Size]
+			proof := MerkleProof(pieces, i)
+			if !VerifyMerkleProof(root, hash, i, proof) {
+				t.Errorf("%d pieces: proof of piece %d rejected", n, i)
+			}
+			if n > 1 && VerifyMerkleProof(root, hash, i^1, proof) {
+				t.Errorf("%d pieces: proof of piece %d accepted for piece %d", n, i, i^1)
+			}
+			if VerifyMerkleProof(root, corruptHash(hash), i, proof) {
+				t.Errorf("%d pieces: corrupt piece %d accepted", n, i)
+			}
+		}
+	}
+}
+
+func corruptHash(hash []byte) []byte {
+	h := append([]byte(nil), hash...)
+	h[0]++
+	return h
+}
+
+func TestWriter_UseMerkle(t *testing.T) {
+	w, err := NewWriterSingle(4, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.UseMerkle()
+	w.Write([]byte("hello, world"))
+	meta, err := w.Metainfo("", "http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Info.Pieces) != 0 || !meta.Info.Merkle() {
+		t.Fatalf("info is not a merkle torrent: %+v", meta.Info)
+	}
+	pw := newPieceWriter(4)
+	pw.Write([]byte("hello, world"))
+	pw.Close()
+	if !bytes.Equal(meta.Info.RootHash, MerkleRoot(pw.Pieces())) {
+		t.Errorf("root hash %x", meta.Info.RootHash)
+	}
+	err = meta.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+	p, err := bencoding.Marshal(meta.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(p, []byte("6:pieces")) || !bytes.Contains(p, []byte("9:root hash20:")) {
+		t.Errorf("encoded info %q", p)
+	}
+}
@@ -17,17 +17,41 @@ package metainfo
 
 import (
 	"crypto/sha1"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/magnet"
 )
 
 // FileInfo serializes one file's metadata in a multi-file Info.
+//
+// Attr and SymlinkPath are extensions described in BEP 47.
+// http://www.bittorrent.org/beps/bep_0047.html
 type FileInfo struct {
-	Path   []string `bencoding:"path"`
-	Length int64    `bencoding:"length"`
-	MD5Sum string   `bencoding:"md5sum,omitempty"`
+	Path        []string `bencoding:"path"`
+	Length      int64    `bencoding:"length"`
+	MD5Sum      string   `bencoding:"md5sum,omitempty"`
+	Attr        string   `bencoding:"attr,omitempty"`
+	SymlinkPath []string `bencoding:"symlink path,omitempty"`
+}
+
+// IsSymlink returns true if the file's attributes mark it as a symbolic link
+// to SymlinkPath.
+func (file FileInfo) IsSymlink() bool {
+	return strings.Contains(file.Attr, "l")
+}
+
+// IsPadding returns true if the file's attributes mark it as a padding file,
+// whose content is zeros that are not stored.
+func (file FileInfo) IsPadding() bool {
+	return strings.Contains(file.Attr, "p")
 }
 
 // Info serializes the BitTorrent info dictionary.
@@ -39,9 +63,26 @@ type Info struct {
 	Files       []FileInfo `bencoding:"files,omitempty"`
 	Length      int64      `bencoding:"length,omitempty"`
 	MD5Sum      string     `bencoding:"md5sum,omitempty"`
-	Pieces      []byte     `bencoding:"pieces"`
+	Pieces      []byte     `bencoding:"pieces,omitempty"`
 	PieceLength int64      `bencoding:"piece length"`
 	Private     bool       `bencoding:"private,omitempty"`
+	Source      string     `bencoding:"source,omitempty"` // private tracker source tag
+
+	// RootHash replaces Pieces in Merkle torrents (BEP 30).  See MerkleRoot.
+	RootHash []byte `bencoding:"root hash,omitempty"`
+
+	// Similar and Collections are described in BEP 38.  Similar holds the
+	// info hashes of torrents sharing files with this one, and Collections
+	// names groups of torrents sharing files.
+	// http://www.bittorrent.org/beps/bep_0038.html
+	Similar     []InfoHash `bencoding:"similar,omitempty"`
+	Collections []string   `bencoding:"collections,omitempty"`
+}
+
+// Merkle returns true if info describes a Merkle torrent, identified by its
+// RootHash instead of Pieces.
+func (info Info) Merkle() bool {
+	return len(info.RootHash) > 0
 }
 
 // Returns true if info is in single-file mode.
@@ -49,28 +90,222 @@ func (info Info) SingleFileMode() bool {
 	return len(info.Files) == 0
 }
 
-// Hash returns the (20 byte) SHA-1 hash of info.
-func (info Info) Hash() ([]byte, error) {
+// WebSeedURL returns the URL of file i of info on the web seed seed (BEP 19).
+// For a single-file torrent i must be 0, and the name of the torrent is
+// appended to seed if it ends in a slash.  For a multi-file torrent, the name
+// of the torrent and the file's path are appended to seed.
+func (info Info) WebSeedURL(seed string, i int) (string, error) {
+	if info.SingleFileMode() {
+		if i != 0 {
+			return "", fmt.Errorf("file %d out of range", i)
+		}
+		if strings.HasSuffix(seed, "/") {
+			seed += url.PathEscape(info.Name)
+		}
+		return seed, nil
+	}
+	if i < 0 || i >= len(info.Files) {
+		return "", fmt.Errorf("file %d out of range", i)
+	}
+	if !strings.HasSuffix(seed, "/") {
+		seed += "/"
+	}
+	seed += url.PathEscape(info.Name)
+	for _, elem := range info.Files[i].Path {
+		seed += "/" + url.PathEscape(elem)
+	}
+	return seed, nil
+}
+
+// Hash returns the SHA-1 hash of info.  The hash is computed from a new
+// encoding of info, which differs from the original encoding of a torrent
+// file with keys unknown to Info.  Use HashBytes or ReadFileWithHash to hash
+// the original encoding.
+func (info Info) Hash() (InfoHash, error) {
 	p, err := bencoding.Marshal(info)
 	if err != nil {
-		return nil, err
+		return InfoHash{}, err
 	}
-	h := sha1.New()
-	_, err = h.Write(p)
+	return sha1.Sum(p), nil
+}
+
+// FrozenInfo is an immutable copy of an Info whose hash is computed at most
+// once.  Use it where the same Info is hashed repeatedly.  Changes to an
+// Info are not reflected in views frozen before the change; freeze the Info
+// again to obtain a view with an up to date hash.
+type FrozenInfo struct {
+	info Info
+	once sync.Once
+	hash InfoHash
+	err  error
+}
+
+// Freeze returns an immutable view of a copy of info.
+func (info Info) Freeze() *FrozenInfo {
+	return &FrozenInfo{info: info.Clone()}
+}
+
+// Info returns a copy of the frozen Info.
+func (f *FrozenInfo) Info() Info {
+	return f.info.Clone()
+}
+
+// Hash returns the SHA-1 hash of the frozen Info.  The hash is computed on
+// the first call and cached.
+func (f *FrozenInfo) Hash() (InfoHash, error) {
+	f.once.Do(func() { f.hash, f.err = f.info.Hash() })
+	return f.hash, f.err
+}
+
+// Clone returns a deep copy of info that shares no memory with it.
+func (info Info) Clone() Info {
+	info.Pieces = append([]byte(nil), info.Pieces...)
+	if info.RootHash != nil {
+		info.RootHash = append([]byte(nil), info.RootHash...)
+	}
+	if info.Files != nil {
+		files := make([]FileInfo, len(info.Files))
+		for i, file := range info.Files {
+			file.Path = append([]string(nil), file.Path...)
+			if file.SymlinkPath != nil {
+				file.SymlinkPath = append([]string(nil), file.SymlinkPath...)
+			}
+			files[i] = file
+		}
+		info.Files = files
+	}
+	if info.Similar != nil {
+		info.Similar = append([]InfoHash(nil), info.Similar...)
+	}
+	if info.Collections != nil {
+		info.Collections = append([]string(nil), info.Collections...)
+	}
+	return info
+}
+
+// Node is a DHT node used to bootstrap a trackerless torrent (BEP 5).  It is
+// encoded as a list of the host and port.
+type Node struct {
+	Host string
+	Port int
+}
+
+func (node Node) String() string {
+	return net.JoinHostPort(node.Host, strconv.Itoa(node.Port))
+}
+
+// MarshalBencoding implements bencoding.Marshaller.
+func (node Node) MarshalBencoding() ([]byte, error) {
+	return bencoding.Marshal([]interface{}{node.Host, node.Port})
+}
+
+// UnmarshalBencoding implements bencoding.Unmarshaller.
+func (node *Node) UnmarshalBencoding(p []byte) error {
+	var v []interface{}
+	err := bencoding.Unmarshal(p, &v)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if len(v) != 2 {
+		return fmt.Errorf("node has %d elements", len(v))
+	}
+	host, ok := v[0].(string)
+	if !ok {
+		return fmt.Errorf("node host is not a string")
 	}
-	return h.Sum(nil), nil
+	port, ok := v[1].(int64)
+	if !ok || port < 0 || port > 65535 {
+		return fmt.Errorf("invalid node port")
+	}
+	node.Host, node.Port = host, int(port)
+	return nil
 }
 
-// Metainfo serializes the BitTorrent metainfo dictionary.
+// URLList is a list of web seed URLs (BEP 19).  It is decoded from either a
+// single string or a list of strings and is always encoded as a list.
+// http://www.bittorrent.org/beps/bep_0019.html
+type URLList []string
+
+// UnmarshalBencoding implements bencoding.Unmarshaller.
+func (list *URLList) UnmarshalBencoding(p []byte) error {
+	if len(p) > 0 && p[0] != 'l' {
+		var seed string
+		err := bencoding.Unmarshal(p, &seed)
+		if err != nil {
+			return err
+		}
+		*list = nil
+		if seed != "" {
+			*list = URLList{seed}
+		}
+		return nil
+	}
+	var urls []string
+	err := bencoding.Unmarshal(p, &urls)
+	if err != nil {
+		return err
+	}
+	*list = urls
+	return nil
+}
+
+// Metainfo serializes the BitTorrent metainfo dictionary.  Trackerless
+// torrents have no Announce or AnnounceList and rely on the DHT, optionally
+// bootstrapped from Nodes.
 type Metainfo struct {
-	Info         Info   `bencoding:"info"`
-	Announce     string `bencoding:"announce"`
-	CreationDate int64  `bencoding:"creation date,omitempty"`
-	Encoding     string `bencoding:"encoding,omitempty"`
-	CreatedBy    string `bencoding:"created by,omitempty"`
-	Comment      string `bencoding:"comment,omitempty"`
+	Info         Info       `bencoding:"info"`
+	Announce     string     `bencoding:"announce,omitempty"`
+	AnnounceList [][]string `bencoding:"announce-list,omitempty"` // BEP 12 tracker tiers
+	Nodes        []Node     `bencoding:"nodes,omitempty"`         // BEP 5 DHT nodes
+	URLList      URLList    `bencoding:"url-list,omitempty"`      // BEP 19 web seeds
+	CreationDate int64      `bencoding:"creation date,omitempty"`
+	Encoding     string     `bencoding:"encoding,omitempty"`
+	CreatedBy    string     `bencoding:"created by,omitempty"`
+	Comment      string     `bencoding:"comment,omitempty"`
+}
+
+// Trackerless returns true if meta names no tracker, leaving peers to be
+// found through the DHT.
+func (meta *Metainfo) Trackerless() bool {
+	return meta.Announce == "" && len(meta.AnnounceList) == 0
+}
+
+// WebSeedURLs returns the URL of file i of meta for each of its web seeds.
+// See Info.WebSeedURL.
+func (meta *Metainfo) WebSeedURLs(i int) ([]string, error) {
+	var urls []string
+	for _, seed := range meta.URLList {
+		u, err := meta.Info.WebSeedURL(seed, i)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// MagnetLink returns a magnet link for meta, naming its trackers and web
+// seeds.  The info hash is computed by Info.Hash, so the link is wrong for a
+// torrent decoded from a file with info keys unknown to Info.  Use
+// MagnetLinkHash with the hash from ReadFileWithHash for such torrents.
+func (meta *Metainfo) MagnetLink() (string, error) {
+	h, err := meta.Info.Hash()
+	if err != nil {
+		return "", err
+	}
+	return meta.MagnetLinkHash(h), nil
+}
+
+// MagnetLinkHash is like MagnetLink but uses the given info hash.
+func (meta *Metainfo) MagnetLinkHash(h InfoHash) string {
+	m := &magnet.Link{InfoHash: h[:], Name: meta.Info.Name, WebSeeds: meta.URLList}
+	for _, tier := range meta.AnnounceList {
+		m.Trackers = append(m.Trackers, tier...)
+	}
+	if len(meta.AnnounceList) == 0 && meta.Announce != "" {
+		m.Trackers = append(m.Trackers, meta.Announce)
+	}
+	return m.String()
 }
 
 // WriteFile creates a (.torrent) metainfo file.
@@ -82,6 +317,69 @@ func WriteFile(filename string, meta *Metainfo, perm os.FileMode) error {
 	return ioutil.WriteFile(filename, p, perm)
 }
 
+// ReadFileWithHash reads a (.torrent) metainfo file and returns it along
+// with its info hash.  The hash is computed from the original encoding of the
+// info dictionary as the file is decoded, so it matches the hash other
+// clients compute even when the file contains keys unknown to Info.
+func ReadFileWithHash(filename string) (*Metainfo, InfoHash, error) {
+	p, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, InfoHash{}, err
+	}
+	return unmarshalWithHash(p)
+}
+
+// unmarshalWithHash decodes the metainfo encoded in p and returns it along
+// with its info hash, computed as by ReadFileWithHash.
+func unmarshalWithHash(p []byte) (*Metainfo, InfoHash, error) {
+	var meta Metainfo
+	var hash InfoHash
+	var found bool
+	dec := bencoding.NewDecoderBytes(p)
+	dec.Hook("info", func(raw []byte) {
+		hash, found = sha1.Sum(raw), true
+	})
+	err := dec.Decode(&meta)
+	if err != nil {
+		return nil, InfoHash{}, err
+	}
+	var rest interface{}
+	if dec.Decode(&rest) != bencoding.EOF {
+		return nil, InfoHash{}, fmt.Errorf("trailing bytes")
+	}
+	if !found {
+		return nil, InfoHash{}, fmt.Errorf("missing info dictionary")
+	}
+	return &meta, hash, nil
+}
+
+// HashBytes returns the info hash of the metainfo encoded in p.  Unlike
+// Info.Hash, which hashes a new encoding of the decoded Info, HashBytes
+// hashes the info dictionary exactly as it appears in p, including keys
+// unknown to Info and any non-canonical key order.  Other fields of the
+// metainfo are not decoded.
+func HashBytes(p []byte) (InfoHash, error) {
+	var raw struct {
+		Info bencoding.RawMessage `bencoding:"info"`
+	}
+	dec := bencoding.NewDecoderBytes(p)
+	err := dec.Decode(&raw)
+	if err != nil {
+		return InfoHash{}, err
+	}
+	var rest interface{}
+	if dec.Decode(&rest) != bencoding.EOF {
+		return InfoHash{}, fmt.Errorf("trailing bytes")
+	}
+	if len(raw.Info) == 0 {
+		return InfoHash{}, fmt.Errorf("missing info dictionary")
+	}
+	if raw.Info[0] != 'd' {
+		return InfoHash{}, fmt.Errorf("info is not a dictionary")
+	}
+	return sha1.Sum(raw.Info), nil
+}
+
 // ReadFile reads a (.torrent) metainfo file.
 func ReadFile(filename string) (*Metainfo, error) {
 	p, err := ioutil.ReadFile(filename)
@@ -7,66 +7,75 @@ package metainfo
  */
 
 import (
+	"crypto/sha1"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
-	"strings"
 	"testing"
 
 	"github.com/bmatsuo/torrent/bencoding"
 )
 
-// TestBencoding tests the bencoding tags on metainfo struct types.
-func TestBencoding(t *testing.T) {
-	cwd, err := os.Getwd()
+// TestReadFileWithHash checks that the hash covers the original encoding of
+// the info dictionary, including keys unknown to Info.
+func TestReadFileWithHash(t *testing.T) {
+	info := "d6:lengthi5e4:name1:a12:piece lengthi16e6:pieces20:01234567890123456789" +
+		"7:unknown3:xyze"
+	torrent := "d8:announce18:http://example.com4:info" + info + "e"
+	dir, err := ioutil.TempDir("", "metainfo-test-")
 	if err != nil {
-		t.Fatalf("failed to determine working directory: %v", err)
+		t.Fatal(err)
 	}
-	testfiles, err := filepath.Glob(filepath.Join(cwd, "test", "torrents", "*"))
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.torrent")
+	err = ioutil.WriteFile(path, []byte(torrent), 0644)
 	if err != nil {
-		t.Fatalf("failed to find test torrent files: %v", err)
+		t.Fatal(err)
 	}
-	if len(testfiles) == 0 {
-		t.Fatalf("no test files found")
+	meta, hash, err := ReadFileWithHash(path)
+	if err != nil {
+		t.Fatal(err)
 	}
-	for _, filename := range testfiles {
-		if !strings.HasSuffix(filename, ".torrent") {
-			t.Logf("skipping non-torrent %q", filename)
-			continue
-		}
-		base := filepath.Base(filename)
-		origp, err := ioutil.ReadFile(filename)
-		if err != nil {
-			t.Errorf("failed to read file: %v", err)
-			continue
-		}
-		var meta Metainfo
-		err = bencoding.Unmarshal(origp, &meta)
-		if err != nil {
-			t.Errorf("failed to read file: %v", err)
-			continue
-		}
-		p, err := bencoding.Marshal(meta)
-		if err != nil {
-			t.Errorf("unable to marshal metainfo for %q: %v", base, err)
-			continue
-		}
-		meta = Metainfo{}
-		err = bencoding.Unmarshal(p, &meta)
-		if err != nil {
-			t.Errorf("unable to parse marshalled output for %q: %v", base, err)
-			continue
-		}
-		cpp, _ := bencoding.Marshal(meta)
-		if len(p) != len(cpp) {
-			t.Errorf("unexpected output size %d for %q (expected %d)", len(p), base, len(cpp))
-			continue
-		}
-		if !reflect.DeepEqual(p, cpp) {
-			t.Logf("expected: %q", p)
-			t.Logf("received: %q", cpp)
-			t.Fatalf("unexpected serialization output for %q", base)
+	expect := InfoHash(sha1.Sum([]byte(info)))
+	if hash != expect {
+		t.Errorf("hash %v (expected %v)", hash, expect)
+	}
+	if meta.Info.Name != "a" {
+		t.Errorf("name %q (expected %q)", meta.Info.Name, "a")
+	}
+	remarshaled, err := meta.Info.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == remarshaled {
+		t.Errorf("hash ignores unknown info keys")
+	}
+}
+
+func TestHashBytes(t *testing.T) {
+	// keys out of order and unknown to Info.
+	info := "d4:name1:a6:lengthi5e12:piece lengthi16e6:pieces20:01234567890123456789" +
+		"7:unknown3:xyze"
+	torrent := "d8:announce18:http://example.com4:info" + info + "e"
+	hash, err := HashBytes([]byte(torrent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := InfoHash(sha1.Sum([]byte(info)))
+	if hash != expect {
+		t.Errorf("hash %v (expected %v)", hash, expect)
+	}
+	for _, bad := range []string{
+		"",
+		"d8:announce18:http://example.come",
+		"d4:infoi1ee",
+		"d4:info" + info + "ei1e",
+		"l" + info + "e",
+	} {
+		if _, err := HashBytes([]byte(bad)); err == nil {
+			t.Errorf("%q: no error", bad)
 		}
 	}
 }
@@ -155,3 +164,116 @@ func BenchmarkMarshal(b *testing.B) {
 	b.StopTimer()
 	b.SetBytes(nbytes)
 }
+
+func TestURLList(t *testing.T) {
+	for i, test := range []struct {
+		enc    string
+		expect URLList
+	}{
+		{"d8:url-list0:e", nil},
+		{"d8:url-listlee", nil},
+		{"d8:url-list9:http://a/e", URLList{"http://a/"}},
+		{"d8:url-listl9:http://a/9:http://b/ee", URLList{"http://a/", "http://b/"}},
+	} {
+		var meta Metainfo
+		err := bencoding.Unmarshal([]byte(test.enc), &meta)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(meta.URLList, test.expect) {
+			t.Errorf("test %d: %q (!= %q)", i, meta.URLList, test.expect)
+		}
+	}
+	var meta Metainfo
+	err := bencoding.Unmarshal([]byte("d8:url-listi1ee"), &meta)
+	if err == nil {
+		t.Errorf("decoded an integer url-list")
+	}
+}
+
+func TestWebSeedURLs(t *testing.T) {
+	meta := &Metainfo{
+		Info: Info{
+			Name: "a b",
+			Files: []FileInfo{
+				{Path: []string{"c", "d#1"}, Length: 1},
+				{Path: []string{"e"}, Length: 1},
+			},
+		},
+		URLList: URLList{"http://s1/files/", "http://s2/x"},
+	}
+	urls, err := meta.WebSeedURLs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"http://s1/files/a%20b/c/d%231", "http://s2/x/a%20b/c/d%231"}
+	if !reflect.DeepEqual(urls, expect) {
+		t.Errorf("multi-file urls %q (!= %q)", urls, expect)
+	}
+	if _, err := meta.WebSeedURLs(2); err == nil {
+		t.Errorf("url for file out of range")
+	}
+
+	meta.Info.Files = nil
+	meta.Info.Length = 2
+	urls, err = meta.WebSeedURLs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = []string{"http://s1/files/a%20b", "http://s2/x"}
+	if !reflect.DeepEqual(urls, expect) {
+		t.Errorf("single-file urls %q (!= %q)", urls, expect)
+	}
+	if _, err := meta.WebSeedURLs(1); err == nil {
+		t.Errorf("url for file out of range")
+	}
+}
+
+func TestMagnetLink(t *testing.T) {
+	meta := &Metainfo{
+		Info: Info{
+			Name:        "a b",
+			Length:      5,
+			Pieces:      []byte("01234567890123456789"),
+			PieceLength: 16,
+		},
+		Announce:     "http://t0/announce",
+		AnnounceList: [][]string{{"http://t1/announce"}, {"udp://t2:80"}},
+	}
+	hash, err := meta.Info.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := meta.MagnetLink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := fmt.Sprintf("magnet:?xt=urn:btih:%v&dn=a+b&tr=http%%3A%%2F%%2Ft1%%2Fannounce&tr=udp%%3A%%2F%%2Ft2%%3A80", hash)
+	if link != expect {
+		t.Errorf("link %s (!= %s)", link, expect)
+	}
+
+	meta.AnnounceList = nil
+	link, err = meta.MagnetLink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = fmt.Sprintf("magnet:?xt=urn:btih:%v&dn=a+b&tr=http%%3A%%2F%%2Ft0%%2Fannounce", hash)
+	if link != expect {
+		t.Errorf("link %s (!= %s)", link, expect)
+	}
+}
+
+func TestMagnetLinkHash(t *testing.T) {
+	info := "d6:lengthi5e4:name1:a12:piece lengthi16e6:pieces20:01234567890123456789" +
+		"7:unknown3:xyze"
+	meta, hash, err := unmarshalWithHash([]byte("d8:announce5:http:4:info" + info + "e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "magnet:?xt=urn:btih:" + InfoHash(sha1.Sum([]byte(info))).Hex() + "&dn=a&tr=http%3A"
+	if link := meta.MagnetLinkHash(hash); link != expect {
+		t.Errorf("link %s (!= %s)", link, expect)
+	}
+}
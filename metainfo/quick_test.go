@@ -0,0 +1,125 @@
+package metainfo_test
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+	"github.com/bmatsuo/torrent/torrenttest"
+)
+
+// TestMetainfo_roundTrip checks that decoding an encoded Metainfo produces
+// the original value.
+func TestMetainfo_roundTrip(t *testing.T) {
+	f := func(q torrenttest.QuickMetainfo) bool {
+		p, err := bencoding.Marshal(q.Metainfo)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		var meta metainfo.Metainfo
+		err = bencoding.Unmarshal(p, &meta)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		if err = meta.Validate(); err != nil {
+			t.Log(err)
+			return false
+		}
+		return reflect.DeepEqual(meta, q.Metainfo)
+	}
+	err := quick.Check(f, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestMetainfo_jsonRoundTrip checks that decoding the JSON encoding of a
+// Metainfo produces the original value.
+func TestMetainfo_jsonRoundTrip(t *testing.T) {
+	f := func(q torrenttest.QuickMetainfo) bool {
+		p, err := json.Marshal(q.Metainfo)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		var meta metainfo.Metainfo
+		err = json.Unmarshal(p, &meta)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		return reflect.DeepEqual(meta, q.Metainfo)
+	}
+	err := quick.Check(f, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestInfo_Hash checks that Hash is the SHA-1 hash of the encoded info and
+// does not change when the info is decoded and hashed again.
+func TestInfo_Hash(t *testing.T) {
+	f := func(q torrenttest.QuickInfo) bool {
+		h1, err := q.Info.Hash()
+		if err != nil {
+			return false
+		}
+		p, err := bencoding.Marshal(q.Info)
+		if err != nil {
+			return false
+		}
+		sum := sha1.Sum(p)
+		var info metainfo.Info
+		err = bencoding.Unmarshal(p, &info)
+		if err != nil {
+			return false
+		}
+		h2, err := info.Hash()
+		if err != nil {
+			return false
+		}
+		return h1 == metainfo.InfoHash(sum) && h1 == h2
+	}
+	err := quick.Check(f, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestInfo_Freeze checks that a frozen Info hashes like the original and is
+// isolated from later changes to it.
+func TestInfo_Freeze(t *testing.T) {
+	f := func(q torrenttest.QuickInfo) bool {
+		expect, err := q.Info.Hash()
+		if err != nil {
+			return false
+		}
+		frozen := q.Info.Freeze()
+		q.Info.Name += "x"
+		if len(q.Info.Pieces) > 0 {
+			q.Info.Pieces[0]++
+		}
+		for i := range q.Info.Files {
+			q.Info.Files[i].Path[0] += "x"
+		}
+		for i := 0; i < 2; i++ {
+			h, err := frozen.Hash()
+			if err != nil || h != expect {
+				return false
+			}
+		}
+		info := frozen.Info()
+		h, err := info.Hash()
+		return err == nil && h == expect
+	}
+	err := quick.Check(f, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
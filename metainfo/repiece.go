@@ -0,0 +1,105 @@
+package metainfo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repiece hashes the local content of meta with a new piece length and
+// returns a torrent with the same files and metadata.  Dir is the directory
+// containing the single file or the torrent's content directory.  Meta must
+// be valid and its local data is assumed to have been verified against it.
+// Padding files are recreated for the new piece length.  The new torrent has
+// a different info hash.
+func Repiece(meta *Metainfo, dir string, plen int64) (*Metainfo, error) {
+	if plen <= 0 {
+		return nil, fmt.Errorf("invalid piece length %d", plen)
+	}
+	err := meta.Info.Validate()
+	if err != nil {
+		return nil, err
+	}
+	info := &meta.Info
+	var w *Writer
+	if info.SingleFileMode() {
+		w, err = NewWriterSingle(plen, info.Name)
+		if err == nil {
+			err = copyFile(w, filepath.Join(dir, info.Name), info.Length)
+		}
+	} else {
+		w, err = NewWriter(plen)
+		for i := 0; err == nil && i < len(info.Files); i++ {
+			err = repieceFile(w, dir, info, &info.Files[i])
+		}
+	}
+	if err == nil && info.Merkle() {
+		w.UseMerkle()
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.SetSimilar(info.Similar...)
+	w.SetCollections(info.Collections...)
+	w.SetPrivate(info.Private)
+	w.SetSource(info.Source)
+	out, err := w.Metainfo(info.Name, meta.Announce)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]FileInfo)
+	for _, file := range info.Files {
+		if !file.IsPadding() {
+			files[strings.Join(file.Path, "/")] = file
+		}
+	}
+	for i, file := range out.Info.Files {
+		if orig, ok := files[strings.Join(file.Path, "/")]; ok {
+			out.Info.Files[i].MD5Sum = orig.MD5Sum
+			out.Info.Files[i].Attr = orig.Attr
+		}
+	}
+	out.AnnounceList = meta.AnnounceList
+	out.Nodes = meta.Nodes
+	out.URLList = meta.URLList
+	out.CreationDate = meta.CreationDate
+	out.Encoding = meta.Encoding
+	out.CreatedBy = meta.CreatedBy
+	out.Comment = meta.Comment
+	return out, nil
+}
+
+func repieceFile(w *Writer, dir string, info *Info, file *FileInfo) error {
+	switch {
+	case file.IsPadding():
+		return w.Pad()
+	case file.IsSymlink():
+		return w.Symlink(file.SymlinkPath, file.Path...)
+	}
+	err := w.Open(file.Path...)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(append([]string{dir, info.Name}, file.Path...)...)
+	return copyFile(w, path, file.Length)
+}
+
+// copyFile writes the content of the file at path to w.  The file must have
+// the expected length.
+func copyFile(w io.Writer, path string, length int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(w, io.LimitReader(f, length+1))
+	if err != nil {
+		return err
+	}
+	if n != length {
+		return fmt.Errorf("%s: length %d (expected %d)", path, n, length)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package metainfo_test
+
+import (
+	"testing"
+
+	"github.com/bmatsuo/torrent/metainfo"
+	"github.com/bmatsuo/torrent/torrenttest"
+)
+
+func TestRepiece(t *testing.T) {
+	for _, c := range []*torrenttest.Content{
+		torrenttest.Generate(1, 32, 100),
+		torrenttest.Generate(2, 32, 10, 0, 90),
+	} {
+		tree := torrenttest.Materialize(t, c, "http://example.com/announce")
+		tree.Meta.Comment = "repiece"
+		tree.Meta.Info.Private = true
+		tree.Meta.URLList = metainfo.URLList{"http://seed.example.com/files/"}
+		if !c.SingleFileMode() {
+			tree.Meta.Info.Files[0].Attr = "x"
+		}
+		meta, err := metainfo.Repiece(tree.Meta, tree.Dir, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c64 := *c
+		c64.PieceLength = 64
+		expect := c64.Metainfo("http://example.com/announce")
+		expect.Comment = "repiece"
+		expect.Info.Private = true
+		expect.URLList = metainfo.URLList{"http://seed.example.com/files/"}
+		if !c.SingleFileMode() {
+			expect.Info.Files[0].Attr = "x"
+		}
+		torrenttest.CompareMetainfo(t, c.Name, meta, expect)
+
+		if c.SingleFileMode() {
+			tree.Meta.Info.Length++
+		} else {
+			tree.Meta.Info.Files[0].Length++
+		}
+		if _, err := metainfo.Repiece(tree.Meta, tree.Dir, 64); err == nil {
+			t.Errorf("%s: repieced data of the wrong length", c.Name)
+		}
+
+		if !c.SingleFileMode() {
+			tree.Meta.Info.Files[0].Length--
+			tree.Meta.Info.Files[0].Path = []string{"..", "..", "etc", "passwd"}
+			if _, err := metainfo.Repiece(tree.Meta, tree.Dir, 64); err == nil {
+				t.Errorf("%s: repieced a path outside of the torrent", c.Name)
+			}
+		}
+	}
+}
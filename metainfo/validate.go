@@ -0,0 +1,114 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Validate returns an error describing the first problem found in meta that
+// would prevent a client from using it.  Trackerless torrents are valid
+// unless they are private, because private torrents may not use the DHT.
+func (meta *Metainfo) Validate() error {
+	if meta.Trackerless() && meta.Info.Private {
+		return fmt.Errorf("no announce url for a private torrent")
+	}
+	for i, node := range meta.Nodes {
+		if node.Host == "" || node.Port <= 0 || node.Port > 65535 {
+			return fmt.Errorf("node %d: invalid address %v", i, node)
+		}
+	}
+	for i, seed := range meta.URLList {
+		u, err := url.Parse(seed)
+		if err != nil {
+			return fmt.Errorf("web seed %d: %v", i, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "ftp":
+		default:
+			return fmt.Errorf("web seed %d: unsupported url %q", i, seed)
+		}
+	}
+	return meta.Info.Validate()
+}
+
+// Validate returns an error if info is malformed.  File names, paths, and
+// symlink targets must be relative and may not escape the torrent's
+// directory, and the number of pieces must match the total length.
+func (info *Info) Validate() error {
+	err := validPathElem(info.Name)
+	if err != nil {
+		return fmt.Errorf("name: %v", err)
+	}
+	if info.PieceLength <= 0 {
+		return fmt.Errorf("invalid piece length %d", info.PieceLength)
+	}
+	if len(info.Pieces)%sha1.Size != 0 {
+		return fmt.Errorf("pieces length %d is not a multiple of %d", len(info.Pieces), sha1.Size)
+	}
+	var total int64
+	if info.SingleFileMode() {
+		if info.Length < 0 {
+			return fmt.Errorf("negative length %d", info.Length)
+		}
+		total = info.Length
+	}
+	for i, file := range info.Files {
+		if file.Length < 0 {
+			return fmt.Errorf("file %d: negative length %d", i, file.Length)
+		}
+		if len(file.Path) == 0 {
+			return fmt.Errorf("file %d: empty path", i)
+		}
+		for _, elem := range file.Path {
+			err = validPathElem(elem)
+			if err != nil {
+				return fmt.Errorf("file %d: %v", i, err)
+			}
+		}
+		// symlink targets are relative to the torrent's directory and
+		// are held to the same rules as file paths.
+		for _, elem := range file.SymlinkPath {
+			err = validPathElem(elem)
+			if err != nil {
+				return fmt.Errorf("file %d: symlink: %v", i, err)
+			}
+		}
+		total += file.Length
+	}
+	if info.Merkle() {
+		if len(info.RootHash) != sha1.Size {
+			return fmt.Errorf("root hash length %d", len(info.RootHash))
+		}
+		if len(info.Pieces) > 0 {
+			return fmt.Errorf("both pieces and root hash present")
+		}
+		return nil
+	}
+	if len(info.Pieces) == 0 {
+		return fmt.Errorf("no pieces")
+	}
+	npieces := (total + info.PieceLength - 1) / info.PieceLength
+	if total == 0 {
+		// Writer hashes empty content as a single empty piece.
+		npieces = 1
+	}
+	if int64(len(info.Pieces)/sha1.Size) != npieces {
+		return fmt.Errorf("%d pieces for %d bytes (expected %d)", len(info.Pieces)/sha1.Size, total, npieces)
+	}
+	return nil
+}
+
+// validPathElem returns an error if elem is not a safe file name.
+func validPathElem(elem string) error {
+	switch {
+	case elem == "":
+		return fmt.Errorf("empty path element")
+	case elem == "." || elem == "..":
+		return fmt.Errorf("invalid path element %q", elem)
+	case strings.ContainsAny(elem, "/\\\x00"):
+		return fmt.Errorf("invalid character in path element %q", elem)
+	}
+	return nil
+}
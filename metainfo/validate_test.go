@@ -0,0 +1,107 @@
+package metainfo
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bmatsuo/torrent/bencoding"
+)
+
+func TestValidate(t *testing.T) {
+	filenames, err := filepath.Glob(filepath.Join("test", "*", "*.torrent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		meta, err := ReadFile(filename)
+		if err != nil {
+			t.Errorf("%s: %v", filename, err)
+			continue
+		}
+		err = meta.Validate()
+		if err != nil {
+			t.Errorf("%s: %v", filename, err)
+		}
+	}
+
+	pieces := make([]byte, 40)
+	for _, test := range []struct {
+		meta Metainfo
+		err  string
+	}{
+		{Metainfo{Info: Info{Name: "a", Length: 5, PieceLength: 4, Pieces: pieces, Private: true}}, "no announce"},
+		{Metainfo{Nodes: []Node{{"", 6881}}, Info: Info{Name: "a", Length: 5, PieceLength: 4, Pieces: pieces}}, "node 0"},
+		{Metainfo{URLList: URLList{"http://a/", "udp://b/"}, Info: Info{Name: "a", Length: 5, PieceLength: 4, Pieces: pieces}}, "web seed 1"},
+		{Metainfo{Announce: "x", Info: Info{Name: "..", Length: 5, PieceLength: 4, Pieces: pieces}}, "name"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", Length: 5, PieceLength: 0, Pieces: pieces}}, "piece length"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", Length: 5, PieceLength: 4, Pieces: pieces[:39]}}, "multiple"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", Length: 9, PieceLength: 4, Pieces: pieces}}, "pieces for"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", Length: 5, PieceLength: 4}}, "no pieces"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", PieceLength: 4, Pieces: pieces}}, "pieces for"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", Length: -1, PieceLength: 4, Pieces: pieces}}, "negative"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", PieceLength: 4, Pieces: pieces,
+			Files: []FileInfo{{Path: []string{"b", "../c"}, Length: 5}}}}, "invalid character"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", PieceLength: 4, Pieces: pieces,
+			Files: []FileInfo{{Path: nil, Length: 5}}}}, "empty path"},
+		{Metainfo{Announce: "x", Info: Info{Name: "a", PieceLength: 4, Pieces: pieces,
+			Files: []FileInfo{{Path: []string{"b"}, Length: 5}, {Path: []string{"c"}, Attr: "l", SymlinkPath: []string{"..", "etc"}}}}}, "symlink"},
+	} {
+		err := test.meta.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%+v: error %v (expected %q)", test.meta, err, test.err)
+		}
+	}
+}
+
+func TestValidate_trackerless(t *testing.T) {
+	meta := Metainfo{
+		Nodes: []Node{{"router.example.com", 6881}},
+		Info:  Info{Name: "a", Length: 5, PieceLength: 4, Pieces: make([]byte, 40)},
+	}
+	if !meta.Trackerless() {
+		t.Errorf("torrent without trackers is not trackerless")
+	}
+	err := meta.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+	p, err := bencoding.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(p), "d4:infod") || !strings.HasSuffix(string(p), "5:nodesll18:router.example.comi6881eeee") {
+		t.Errorf("encoded %q", p)
+	}
+	var decoded Metainfo
+	err = bencoding.Unmarshal(p, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Nodes) != 1 || decoded.Nodes[0] != meta.Nodes[0] {
+		t.Errorf("decoded nodes %v", decoded.Nodes)
+	}
+}
+
+func TestValidate_empty(t *testing.T) {
+	w, err := NewWriterSingle(1<<14, "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := w.Metainfo("empty", "http://tracker.example.com/announce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = meta.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInfo_shortSimilar(t *testing.T) {
+	var info Info
+	err := bencoding.Unmarshal([]byte("d7:similarl19:0123456789012345678ee"), &info)
+	if err == nil {
+		t.Errorf("decoded short similar hash %v", info.Similar)
+	}
+}
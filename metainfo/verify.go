@@ -0,0 +1,209 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Verifier checks a torrent's content against the piece hashes of its Info.
+type Verifier struct {
+	info   *Info
+	r      io.ReaderAt
+	length int64
+	closer io.Closer
+}
+
+// NewVerifier returns a Verifier that reads the content described by info
+// from r, which holds the torrent's files concatenated in order (including
+// any padding).  Merkle torrents are not supported.
+func NewVerifier(info *Info, r io.ReaderAt) (*Verifier, error) {
+	if info.Merkle() {
+		return nil, fmt.Errorf("merkle torrents are not supported")
+	}
+	if info.PieceLength <= 0 {
+		return nil, fmt.Errorf("invalid piece length %d", info.PieceLength)
+	}
+	if len(info.Pieces)%sha1.Size != 0 {
+		return nil, fmt.Errorf("pieces length %d is not a multiple of %d", len(info.Pieces), sha1.Size)
+	}
+	v := &Verifier{info: info, r: r, length: info.Length}
+	if !info.SingleFileMode() {
+		v.length = 0
+		for _, file := range info.Files {
+			v.length += file.Length
+		}
+	}
+	return v, nil
+}
+
+// NewDirVerifier returns a Verifier that reads the content described by info
+// from files under dir, laid out as a client would save them: dir/<name> for
+// single-file torrents and dir/<name>/<path> for multi-file torrents.
+// Padding files are read as zeros.  Missing and truncated files make the
+// pieces they overlap bad.  The Verifier must be closed when it is no longer
+// needed.
+func NewDirVerifier(info *Info, dir string) (*Verifier, error) {
+	files := new(fileSet)
+	if info.SingleFileMode() {
+		files.add(filepath.Join(dir, info.Name), info.Length, false)
+	}
+	for _, file := range info.Files {
+		parts := append([]string{dir, info.Name}, file.Path...)
+		files.add(filepath.Join(parts...), file.Length, file.IsPadding())
+	}
+	v, err := NewVerifier(info, files)
+	if err != nil {
+		return nil, err
+	}
+	v.closer = files
+	return v, nil
+}
+
+// NumPieces returns the number of pieces in the torrent.
+func (v *Verifier) NumPieces() int {
+	return len(v.info.Pieces) / sha1.Size
+}
+
+// VerifyPiece returns true if the content of piece i matches its hash.  Data
+// that is missing or short makes the piece bad without returning an error.
+func (v *Verifier) VerifyPiece(i int) (bool, error) {
+	if i < 0 || i >= v.NumPieces() {
+		return false, fmt.Errorf("piece %d out of range", i)
+	}
+	off := int64(i) * v.info.PieceLength
+	n := v.info.PieceLength
+	if off+n > v.length {
+		n = v.length - off
+	}
+	if n < 0 {
+		return false, nil
+	}
+	p := make([]byte, n)
+	_, err := v.r.ReadAt(p, off)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("piece %d: %v", i, err)
+	}
+	h := sha1.Sum(p)
+	return bytes.Equal(h[:], v.info.Pieces[i*sha1.Size:(i+1)*sha1.Size]), nil
+}
+
+// Verify checks every piece and returns a slice whose ith element is true if
+// piece i is good.  Verify stops at the first error other than missing data.
+func (v *Verifier) Verify() ([]bool, error) {
+	good := make([]bool, v.NumPieces())
+	for i := range good {
+		ok, err := v.VerifyPiece(i)
+		if err != nil {
+			return good, err
+		}
+		good[i] = ok
+	}
+	return good, nil
+}
+
+// Close releases files opened by a Verifier returned from NewDirVerifier.
+func (v *Verifier) Close() error {
+	if v.closer == nil {
+		return nil
+	}
+	return v.closer.Close()
+}
+
+// fileSetEntry is a file in a fileSet.
+type fileSetEntry struct {
+	path   string
+	offset int64
+	length int64
+	pad    bool
+}
+
+// fileSet is an io.ReaderAt over the concatenation of local files.  At most
+// one file is open at a time.
+type fileSet struct {
+	files  []fileSetEntry
+	length int64
+	mut    sync.Mutex
+	open   string
+	f      *os.File
+}
+
+func (s *fileSet) add(path string, length int64, pad bool) {
+	s.files = append(s.files, fileSetEntry{path, s.length, length, pad})
+	s.length += length
+}
+
+func (s *fileSet) ReadAt(p []byte, off int64) (int, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	var n int
+	for _, file := range s.files {
+		if len(p) == 0 {
+			break
+		}
+		end := file.offset + file.length
+		if off >= end || file.length == 0 {
+			continue
+		}
+		m := int64(len(p))
+		if off+m > end {
+			m = end - off
+		}
+		err := s.readFile(file, p[:m], off-file.offset)
+		if err != nil {
+			return n, err
+		}
+		n += int(m)
+		p = p[m:]
+		off += m
+	}
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readFile fills p from file starting at off.
+func (s *fileSet) readFile(file fileSetEntry, p []byte, off int64) error {
+	if file.pad {
+		for i := range p {
+			p[i] = 0
+		}
+		return nil
+	}
+	if s.open != file.path {
+		s.close()
+		f, err := os.Open(file.path)
+		if err != nil {
+			return err
+		}
+		s.open, s.f = file.path, f
+	}
+	_, err := s.f.ReadAt(p, off)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (s *fileSet) close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.open, s.f = "", nil
+	return err
+}
+
+func (s *fileSet) Close() error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.close()
+}
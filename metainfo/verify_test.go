@@ -0,0 +1,67 @@
+package metainfo_test
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/torrent/metainfo"
+	"github.com/bmatsuo/torrent/torrenttest"
+)
+
+func TestVerifier(t *testing.T) {
+	c := torrenttest.Generate(1, 32, 100)
+	var data []byte
+	for _, file := range c.Files {
+		data = append(data, file.Data...)
+	}
+	info := &c.Metainfo("").Info
+	data[40] ^= 0xff
+	v, err := metainfo.NewVerifier(info, bytes.NewReader(data[:96]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, err := v.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []bool{true, false, true, false}
+	if !reflect.DeepEqual(good, expect) {
+		t.Errorf("good %v (!= %v)", good, expect)
+	}
+	if _, err := v.VerifyPiece(4); err == nil {
+		t.Errorf("verified piece out of range")
+	}
+}
+
+func TestDirVerifier(t *testing.T) {
+	c := torrenttest.Generate(2, 32, 10, 0, 90, 30)
+	tree := torrenttest.Materialize(t, c, "http://example.com/announce")
+	verify := func() []bool {
+		v, err := metainfo.NewDirVerifier(&tree.Meta.Info, tree.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer v.Close()
+		good, err := v.Verify()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return good
+	}
+	if good, expect := verify(), []bool{true, true, true, true, true}; !reflect.DeepEqual(good, expect) {
+		t.Errorf("good %v (!= %v)", good, expect)
+	}
+	tree.CorruptPieces(t, 1)
+	if good, expect := verify(), []bool{true, false, true, true, true}; !reflect.DeepEqual(good, expect) {
+		t.Errorf("corrupt: good %v (!= %v)", good, expect)
+	}
+	err := os.Remove(tree.Paths[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if good, expect := verify(), []bool{true, false, true, false, false}; !reflect.DeepEqual(good, expect) {
+		t.Errorf("missing: good %v (!= %v)", good, expect)
+	}
+}
@@ -5,25 +5,74 @@ import (
 	"crypto/sha1"
 	"fmt"
 	"hash"
+	"io"
+	"runtime"
+	"strconv"
 	"sync"
 )
 
 var errClosed = fmt.Errorf("closed")
 
+// BatchHasher is a hash.Hash that can also hash many whole pieces at once,
+// as vectorized implementations do.  HashPieces appends the sum of each
+// consecutive plen bytes of p to dst and returns the extended slice.  The
+// length of p is always a multiple of plen.  HashPieces must not change the
+// state of the running hash.
+type BatchHasher interface {
+	hash.Hash
+	HashPieces(dst, p []byte, plen int64) []byte
+}
+
+// pieceWriter computes the hashes of consecutive pieces of its input.  A
+// pieceWriter is not safe for concurrent use; Writer serializes access.
+//
+// With more than one worker, complete pieces are copied and hashed by a pool
+// of goroutines.  Their sums are collected in order, waiting on the oldest
+// piece when too many are in flight.
 type pieceWriter struct {
-	mut    sync.Mutex
 	pieces []byte
 	plen   int64
 	offset int64
+	hashfn func() hash.Hash
 	sha    hash.Hash
+	batch  BatchHasher
 	closed bool
+
+	workers int
+	cur     []byte // the current piece, when hashed by workers
+	work    chan *pieceJob
+	queue   []*pieceJob // pieces in flight, in order
+	free    [][]byte    // piece buffers for reuse
+}
+
+// pieceJob is a piece hashed by a pieceWriter worker.
+type pieceJob struct {
+	data []byte
+	sum  []byte
+	done chan struct{}
 }
 
 func newPieceWriter(plen int64) *pieceWriter {
-	return &pieceWriter{
-		plen: plen,
-		sha:  sha1.New(),
-	}
+	w := &pieceWriter{plen: plen, workers: 1}
+	w.setHash(sha1.New)
+	return w
+}
+
+func (w *pieceWriter) setHash(fn func() hash.Hash) {
+	w.hashfn = fn
+	w.sha = fn()
+	w.batch, _ = w.sha.(BatchHasher)
+}
+
+// written returns true if data has been written to w.
+func (w *pieceWriter) written() bool {
+	return w.offset > 0 || len(w.pieces) > 0 || len(w.queue) > 0
+}
+
+// concurrent returns true if pieces are hashed by workers.  Batch hashers
+// are already parallel and always hash on the calling goroutine.
+func (w *pieceWriter) concurrent() bool {
+	return w.workers > 1 && w.batch == nil
 }
 
 func (w *pieceWriter) nonnil() {
@@ -34,62 +83,140 @@ func (w *pieceWriter) nonnil() {
 
 func (w *pieceWriter) Pieces() []byte {
 	w.nonnil()
-	w.mut.Lock()
-	defer w.mut.Unlock()
 	return append([]byte(nil), w.pieces...)
 }
 
 func (w *pieceWriter) Close() error {
 	w.nonnil()
-	w.mut.Lock()
-	defer w.mut.Unlock()
 	if w.closed {
 		return errClosed
 	}
-	if w.sha == nil {
-		w.sha = sha1.New()
+	if w.concurrent() {
+		w.submit()
+		for len(w.queue) > 0 {
+			w.collect()
+		}
+		if w.work != nil {
+			close(w.work)
+			w.work = nil
+		}
+	} else {
+		w.pieces = w.sha.Sum(w.pieces)
 	}
-	w.pieces = append(w.pieces, w.sha.Sum(nil)...)
-	w.sha = nil
+	w.closed = true
 	return nil
 }
 
+// Write hashes p, completing a piece each time a piece boundary is crossed.
 func (w *pieceWriter) Write(p []byte) (int, error) {
 	w.nonnil()
-	w.mut.Lock()
-	defer w.mut.Unlock()
-	return w.write(p)
-}
-
-func (w *pieceWriter) write(p []byte) (int, error) {
 	if w.closed {
 		return 0, errClosed
 	}
-	var prefix, suffix []byte
-	cut := w.plen - w.offset
-	n := len(p)
-	if int64(n) > cut {
-		prefix, suffix = p[:int(cut)], p[int(cut):]
-	} else {
-		prefix = p
+	if w.concurrent() {
+		return w.writeConcurrent(p)
 	}
-	if w.sha == nil {
-		w.sha = sha1.New()
+	n := len(p)
+	for len(p) > 0 {
+		if w.batch != nil && w.offset == 0 && int64(len(p)) > w.plen {
+			// a piece is only complete once data beyond it is written.
+			k := (int64(len(p)) - 1) / w.plen * w.plen
+			w.pieces = w.batch.HashPieces(w.pieces, p[:k], w.plen)
+			p = p[k:]
+		}
+		chunk := p
+		if rem := w.plen - w.offset; int64(len(chunk)) > rem {
+			chunk = chunk[:rem]
+		}
+		w.sha.Write(chunk)
+		w.offset += int64(len(chunk))
+		p = p[len(chunk):]
+		if len(p) > 0 {
+			w.pieces = w.sha.Sum(w.pieces)
+			w.sha.Reset()
+			w.offset = 0
+		}
 	}
-	w.sha.Write(prefix)
-	if len(suffix) > 0 {
-		w.pieces = append(w.pieces, w.sha.Sum(nil)...)
-		w.sha = sha1.New()
-		w.offset = 0
-		_n, err := w.write(suffix)
-		return n + _n, err
+	return n, nil
+}
+
+func (w *pieceWriter) writeConcurrent(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if w.cur == nil {
+			w.cur = w.buffer()
+		}
+		chunk := p
+		if rem := w.plen - w.offset; int64(len(chunk)) > rem {
+			chunk = chunk[:rem]
+		}
+		w.cur = append(w.cur, chunk...)
+		w.offset += int64(len(chunk))
+		p = p[len(chunk):]
+		if len(p) > 0 {
+			w.submit()
+		}
 	}
 	return n, nil
 }
 
+// buffer returns an empty piece buffer.
+func (w *pieceWriter) buffer() []byte {
+	if k := len(w.free); k > 0 {
+		buf := w.free[k-1]
+		w.free = w.free[:k-1]
+		return buf[:0]
+	}
+	return make([]byte, 0, w.plen)
+}
+
+// submit queues the current piece for hashing, starting the workers if
+// necessary.
+func (w *pieceWriter) submit() {
+	if w.work == nil {
+		w.work = make(chan *pieceJob, w.workers)
+		for i := 0; i < w.workers; i++ {
+			go hashPieces(w.hashfn(), w.work)
+		}
+	}
+	if len(w.queue) >= 2*w.workers {
+		w.collect()
+	}
+	job := &pieceJob{data: w.cur, done: make(chan struct{})}
+	w.queue = append(w.queue, job)
+	w.work <- job
+	w.cur = nil
+	w.offset = 0
+}
+
+// collect waits for the oldest piece in flight and appends its sum to
+// w.pieces.
+func (w *pieceWriter) collect() {
+	job := w.queue[0]
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	<-job.done
+	w.pieces = append(w.pieces, job.sum...)
+	w.free = append(w.free, job.data)
+}
+
+// hashPieces is a pieceWriter worker.  It hashes the pieces received from
+// work until the channel is closed.
+func hashPieces(h hash.Hash, work <-chan *pieceJob) {
+	for job := range work {
+		h.Reset()
+		h.Write(job.data)
+		job.sum = h.Sum(nil)
+		close(job.done)
+	}
+}
+
+// fileInfoWriter tracks the length and checksum of one file written through
+// a pieceWriter.  Like pieceWriter it relies on Writer for synchronization.
 type fileInfoWriter struct {
 	path   []string
-	mut    sync.Mutex
+	link   []string
+	pad    bool
 	w      *pieceWriter
 	length int64
 	md5    hash.Hash
@@ -114,8 +241,6 @@ func (h *fileInfoWriter) nonnil() {
 
 func (h *fileInfoWriter) Write(p []byte) (int, error) {
 	h.nonnil()
-	h.mut.Lock()
-	defer h.mut.Unlock()
 	n, err := h.w.Write(p)
 	if n > 0 {
 		h.md5.Write(p[:n])
@@ -124,12 +249,12 @@ func (h *fileInfoWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// Close marks the file complete.  The underlying pieceWriter is not closed
+// because pieces span file boundaries.
 func (h *fileInfoWriter) Close() error {
 	h.nonnil()
-	h.mut.Lock()
-	defer h.mut.Unlock()
 	h.closed = true
-	return h.w.Close()
+	return nil
 }
 
 func (h *fileInfoWriter) MD5Sum() []byte {
@@ -145,6 +270,12 @@ type Writer struct {
 	single bool
 	plen   int64
 	w      *pieceWriter
+
+	similar     []InfoHash
+	collections []string
+	merkle      bool
+	private     bool
+	source      string
 }
 
 // NewWriter allocates and returns a new Writer.
@@ -166,10 +297,101 @@ func NewWriterSingle(plen int64, name string) (*Writer, error) {
 	t.mut.Lock()
 	defer t.mut.Unlock()
 	err = t.open([]string{name})
+	if err != nil {
+		return nil, err
+	}
 	t.single = true
 	return t, nil
 }
 
+// SetHash replaces the SHA1 hash used to compute pieces with hashes
+// allocated by fn, such as an accelerated SHA1 implementation.  If the hash
+// implements BatchHasher, whole pieces are hashed in batches.  Hashes with a
+// size other than 20 bytes do not produce valid Info pieces.  SetHash
+// returns an error if data has already been written to t.
+func (t *Writer) SetHash(fn func() hash.Hash) error {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.closed {
+		return errClosed
+	}
+	if t.w.written() {
+		return fmt.Errorf("data already written")
+	}
+	t.w.setHash(fn)
+	return nil
+}
+
+// SetConcurrency sets the number of goroutines that hash pieces for t.  If n
+// is less than one, runtime.GOMAXPROCS(0) goroutines are used.  By default
+// pieces are hashed as they are written, without additional goroutines.
+// Concurrency has no effect for hashes implementing BatchHasher.  Memory use
+// grows with n because pieces waiting to be hashed are buffered.  Workers
+// exit when t is closed, so a Writer that hashes concurrently must be
+// closed.  SetConcurrency returns an error if data has already been written
+// to t.
+func (t *Writer) SetConcurrency(n int) error {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.closed {
+		return errClosed
+	}
+	if t.w.written() {
+		return fmt.Errorf("data already written")
+	}
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	t.w.workers = n
+	return nil
+}
+
+// UseMerkle causes t to produce a Merkle torrent (BEP 30), whose Info has a
+// RootHash computed from the piece hashes instead of Pieces.
+func (t *Writer) UseMerkle() {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.merkle = true
+}
+
+// SetPrivate sets the private flag of the torrent's Info.  Setting it on the
+// Writer, rather than on the returned Metainfo, makes the Info and its hash
+// final when Metainfo returns.
+func (t *Writer) SetPrivate(private bool) {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.private = private
+}
+
+// SetSource sets the source tag of the torrent's Info.
+func (t *Writer) SetSource(source string) {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.source = source
+}
+
+// SetSimilar sets the info hashes of torrents that share files with t
+// (BEP 38).
+func (t *Writer) SetSimilar(hashes ...InfoHash) {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.similar = append([]InfoHash(nil), hashes...)
+}
+
+// SetCollections sets the names of the collections t belongs to (BEP 38).
+func (t *Writer) SetCollections(names ...string) {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.collections = append([]string(nil), names...)
+}
+
 func (t *Writer) nonnil() {
 	if t == nil {
 		panic("nil torrent")
@@ -179,6 +401,9 @@ func (t *Writer) nonnil() {
 // Open creates a new file entry in t.  Subsequent calls to Write increment
 // the file's length counter.
 func (t *Writer) Open(path ...string) error {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
 	return t.open(path)
 }
 
@@ -198,6 +423,47 @@ func (t *Writer) open(path []string) error {
 	return nil
 }
 
+// Symlink creates a symbolic link entry in t pointing to target, a path
+// relative to the torrent's root directory (BEP 47).  Symbolic links have no
+// content and Write returns an error until another file is opened.
+func (t *Writer) Symlink(target []string, path ...string) error {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if len(target) == 0 {
+		return fmt.Errorf("empty symlink target")
+	}
+	err := t.open(path)
+	if err != nil {
+		return err
+	}
+	t.file.link = target
+	return nil
+}
+
+// Pad adds a padding file (BEP 47) so that the next file opened starts at a
+// piece boundary.  Nothing is added if t is already at a boundary.  Like a
+// symlink, the padding file cannot be written to.
+func (t *Writer) Pad() error {
+	t.nonnil()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.single {
+		return fmt.Errorf("single-file writer cannot pad")
+	}
+	n := t.plen - t.w.offset
+	if t.w.offset == 0 || n == 0 {
+		return nil
+	}
+	err := t.open([]string{".pad", strconv.FormatInt(n, 10)})
+	if err != nil {
+		return err
+	}
+	t.file.pad = true
+	_, err = t.file.Write(make([]byte, n))
+	return err
+}
+
 // Write adds bytes to t's open file.  Write returns an error t if t.Open() has
 // not been called.
 func (t *Writer) Write(p []byte) (int, error) {
@@ -210,9 +476,33 @@ func (t *Writer) Write(p []byte) (int, error) {
 	if t.file == nil {
 		return 0, fmt.Errorf("no open file")
 	}
+	if t.file.link != nil {
+		return 0, fmt.Errorf("cannot write to a symlink")
+	}
+	if t.file.pad {
+		return 0, fmt.Errorf("cannot write to a padding file")
+	}
 	return t.file.Write(p)
 }
 
+// AddReader adds a file to t with the content read from r until EOF, such as
+// the body of an HTTP response.  In single-file mode path must be empty and
+// r supplies the content of the torrent's file.  To keep a copy of content
+// while hashing it, r can be an io.TeeReader.
+func (t *Writer) AddReader(r io.Reader, path ...string) (int64, error) {
+	t.nonnil()
+	if t.single && len(path) > 0 {
+		return 0, fmt.Errorf("single-file writer cannot create new files")
+	}
+	if !t.single {
+		err := t.Open(path...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return io.Copy(t, r)
+}
+
 // Close flushes checksum buffers and prevents future write operations on t.
 func (t *Writer) Close() error {
 	t.nonnil()
@@ -225,8 +515,8 @@ func (t *Writer) Close() error {
 		t.file.Close()
 		t.file = nil
 	}
-	t.w.Close()
-	return nil
+	t.closed = true
+	return t.w.Close()
 }
 
 // Metainfo returns a skeleton Metainfo object from bytes written to t.
@@ -237,6 +527,8 @@ func (t *Writer) Metainfo(dir, announce string) (*Metainfo, error) {
 	if err != nil && err != errClosed {
 		return nil, err
 	}
+	t.mut.Lock()
+	defer t.mut.Unlock()
 	if t.single {
 		return t.metainfoSingle(dir, announce)
 	} else {
@@ -255,10 +547,17 @@ func (t *Writer) metainfoMulti(dir, announce string) (*Metainfo, error) {
 		if t.single {
 			fileinfo.MD5Sum = fmt.Sprintf("%x", file.md5.Sum(nil))
 		}
+		if file.link != nil {
+			fileinfo.Attr = "l"
+			fileinfo.SymlinkPath = file.link
+		}
+		if file.pad {
+			fileinfo.Attr = "p"
+		}
 		info.Files = append(info.Files, fileinfo)
 	}
-	info.Pieces = t.w.Pieces()
-	return &Metainfo{Info: info}, nil
+	t.setInfo(&info)
+	return &Metainfo{Info: info, Announce: announce}, nil
 }
 
 func (t *Writer) metainfoSingle(_, announce string) (*Metainfo, error) {
@@ -266,6 +565,20 @@ func (t *Writer) metainfoSingle(_, announce string) (*Metainfo, error) {
 	info.Name = t.files[0].path[0]
 	info.Length = t.files[0].length
 	info.MD5Sum = fmt.Sprintf("%x", t.files[0].MD5Sum())
+	t.setInfo(&info)
+	return &Metainfo{Info: info, Announce: announce}, nil
+}
+
+// setInfo sets the fields of info common to single and multi-file mode.
+func (t *Writer) setInfo(info *Info) {
 	info.Pieces = t.w.Pieces()
-	return &Metainfo{Info: info}, nil
+	info.PieceLength = t.plen
+	if t.merkle {
+		info.RootHash = MerkleRoot(info.Pieces)
+		info.Pieces = nil
+	}
+	info.Similar = t.similar
+	info.Collections = t.collections
+	info.Private = t.private
+	info.Source = t.source
 }
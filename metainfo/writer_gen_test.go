@@ -0,0 +1,58 @@
+package metainfo_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/torrent/metainfo"
+	"github.com/bmatsuo/torrent/torrenttest"
+)
+
+// TestWriter_generated checks Writer output for generated content with files
+// that start, end, and span piece boundaries.
+func TestWriter_generated(t *testing.T) {
+	const plen = 64
+	for i, sizes := range [][]int64{
+		{1},
+		{plen},
+		{plen*3 + 1},
+		{plen, plen},
+		{10, 0, 54, 65, 200},
+		{1, 1, 1},
+		{0},
+		{0, 0},
+	} {
+		content := torrenttest.Generate(int64(i), plen, sizes...)
+		expect := content.Metainfo("http://example.com/announce")
+		var w *metainfo.Writer
+		var err error
+		if content.SingleFileMode() {
+			w, err = metainfo.NewWriterSingle(plen, content.Name)
+		} else {
+			w, err = metainfo.NewWriter(plen)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range content.Files {
+			if file.Path != nil {
+				err = w.Open(file.Path...)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err = w.Write(file.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		meta, err := w.Metainfo(content.Name, expect.Announce)
+		if err != nil {
+			t.Errorf("sizes %v: %v", sizes, err)
+			continue
+		}
+		if !reflect.DeepEqual(meta, expect) {
+			t.Errorf("sizes %v: metainfo %+v (expected %+v)", sizes, meta, expect)
+		}
+	}
+}
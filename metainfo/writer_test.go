@@ -1,7 +1,441 @@
 package metainfo
 
-import "testing"
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
 
+// TestWriter checks Writer output against torrents created by mktorrent 1.0
+// from the files in test/mktorrent.
 func TestWriter(t *testing.T) {
-	t.Logf("not implemented")
+	dir := filepath.Join("test", "mktorrent")
+	for _, test := range []struct {
+		torrent string
+		files   []string // nil for single-file torrents
+	}{
+		{"test-single.md.torrent", nil},
+		{"test-longsingle.md.torrent", nil},
+		{"test-multi.torrent", []string{
+			"test-longsingle-1.md",
+			"test-longsingle-2.md",
+			"test-longsingle-3.md",
+		}},
+	} {
+		expect, err := ReadFile(filepath.Join(dir, test.torrent))
+		if err != nil {
+			t.Errorf("%s: %v", test.torrent, err)
+			continue
+		}
+		info := expect.Info
+		var w *Writer
+		if test.files == nil {
+			w, err = NewWriterSingle(info.PieceLength, info.Name)
+		} else {
+			w, err = NewWriter(info.PieceLength)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.torrent, err)
+			continue
+		}
+		if test.files == nil {
+			p, err := ioutil.ReadFile(filepath.Join(dir, info.Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(p)
+		}
+		for _, name := range test.files {
+			p, err := ioutil.ReadFile(filepath.Join(dir, info.Name, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// write in uneven chunks to exercise piece boundaries.
+			for len(p) > 0 {
+				n := 1000
+				if n > len(p) {
+					n = len(p)
+				}
+				w.Write(p[:n])
+				p = p[n:]
+			}
+		}
+		meta, err := w.Metainfo(info.Name, expect.Announce)
+		if err != nil {
+			t.Errorf("%s: %v", test.torrent, err)
+			continue
+		}
+		if meta.Announce != expect.Announce {
+			t.Errorf("%s: announce %q (expected %q)", test.torrent, meta.Announce, expect.Announce)
+		}
+		if meta.Info.Name != info.Name {
+			t.Errorf("%s: name %q (expected %q)", test.torrent, meta.Info.Name, info.Name)
+		}
+		if meta.Info.PieceLength != info.PieceLength {
+			t.Errorf("%s: piece length %d (expected %d)", test.torrent, meta.Info.PieceLength, info.PieceLength)
+		}
+		if meta.Info.Length != info.Length {
+			t.Errorf("%s: length %d (expected %d)", test.torrent, meta.Info.Length, info.Length)
+		}
+		if len(meta.Info.Files) != len(info.Files) {
+			t.Errorf("%s: %d files (expected %d)", test.torrent, len(meta.Info.Files), len(info.Files))
+		}
+		if !bytes.Equal(meta.Info.Pieces, info.Pieces) {
+			t.Errorf("%s: %d bytes of pieces do not match expected %d bytes", test.torrent, len(meta.Info.Pieces), len(info.Pieces))
+		}
+	}
+}
+
+// TestPieceWriter_chunks checks that piece hashes do not depend on how the
+// input is split across calls to Write.
+func TestPieceWriter_chunks(t *testing.T) {
+	const plen = 64
+	p := make([]byte, 10*plen+7)
+	for i := range p {
+		p[i] = byte(i * 7)
+	}
+	whole := newPieceWriter(plen)
+	whole.Write(p)
+	whole.Close()
+	expect := whole.Pieces()
+	if len(expect) != 11*20 {
+		t.Fatalf("%d bytes of pieces (expected %d)", len(expect), 11*20)
+	}
+	for _, size := range []int{1, 7, plen - 1, plen, plen + 1, 3 * plen} {
+		w := newPieceWriter(plen)
+		for q := p; len(q) > 0; {
+			n := size
+			if n > len(q) {
+				n = len(q)
+			}
+			w.Write(q[:n])
+			q = q[n:]
+		}
+		w.Close()
+		if !bytes.Equal(w.Pieces(), expect) {
+			t.Errorf("chunk size %d: pieces do not match", size)
+		}
+	}
+}
+
+// TestPieceWriter_concurrent checks that pieces hashed by workers match those
+// hashed sequentially.
+func TestPieceWriter_concurrent(t *testing.T) {
+	const plen = 64
+	p := make([]byte, 50*plen+7)
+	for i := range p {
+		p[i] = byte(i * 7)
+	}
+	seq := newPieceWriter(plen)
+	seq.Write(p)
+	seq.Close()
+	expect := seq.Pieces()
+	for _, workers := range []int{2, 3, 8} {
+		for _, size := range []int{1, plen - 1, plen, plen + 1, 7 * plen, len(p)} {
+			w := newPieceWriter(plen)
+			w.workers = workers
+			for q := p; len(q) > 0; {
+				n := size
+				if n > len(q) {
+					n = len(q)
+				}
+				w.Write(q[:n])
+				q = q[n:]
+			}
+			w.Close()
+			if !bytes.Equal(w.Pieces(), expect) {
+				t.Errorf("%d workers, chunk size %d: pieces do not match", workers, size)
+			}
+		}
+	}
+
+	for _, n := range []int{0, plen} {
+		seq := newPieceWriter(plen)
+		seq.Write(p[:n])
+		seq.Close()
+		w := newPieceWriter(plen)
+		w.workers = 4
+		w.Write(p[:n])
+		w.Close()
+		if !bytes.Equal(w.Pieces(), seq.Pieces()) {
+			t.Errorf("%d bytes: pieces do not match", n)
+		}
+	}
+}
+
+func TestWriter_SetConcurrency(t *testing.T) {
+	w, err := NewWriter(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.SetConcurrency(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.w.workers < 1 {
+		t.Errorf("%d workers", w.w.workers)
+	}
+	w.Open("a")
+	w.Write(make([]byte, 100))
+	err = w.SetConcurrency(2)
+	if err == nil {
+		t.Errorf("SetConcurrency succeeded after writing")
+	}
+	meta, err := w.Metainfo("dir", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Info.Pieces) != 2*sha1.Size {
+		t.Errorf("%d bytes of pieces", len(meta.Info.Pieces))
+	}
+}
+
+func BenchmarkWriter(b *testing.B) {
+	p := make([]byte, 1<<20)
+	w, err := NewWriterSingle(1<<16, "bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(p)))
+	for i := 0; i < b.N; i++ {
+		w.Write(p)
+	}
+}
+
+func benchmarkWriterConcurrency(b *testing.B, n int) {
+	p := make([]byte, 4<<20)
+	w, err := NewWriterSingle(1<<18, "bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = w.SetConcurrency(n)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(p)))
+	for i := 0; i < b.N; i++ {
+		w.Write(p)
+	}
+	w.Close()
+}
+
+func BenchmarkWriter_sequential(b *testing.B)  { benchmarkWriterConcurrency(b, 1) }
+func BenchmarkWriter_concurrent2(b *testing.B) { benchmarkWriterConcurrency(b, 2) }
+func BenchmarkWriter_concurrent4(b *testing.B) { benchmarkWriterConcurrency(b, 4) }
+func BenchmarkWriter_gomaxprocs(b *testing.B)  { benchmarkWriterConcurrency(b, 0) }
+
+// batchSHA1 is a BatchHasher that counts the pieces it hashes in batches.
+type batchSHA1 struct {
+	hash.Hash
+	batched *int
+}
+
+func (h batchSHA1) HashPieces(dst, p []byte, plen int64) []byte {
+	for ; len(p) > 0; p = p[plen:] {
+		sum := sha1.Sum(p[:plen])
+		dst = append(dst, sum[:]...)
+		*h.batched++
+	}
+	return dst
+}
+
+func TestWriter_SetHash(t *testing.T) {
+	const plen = 64
+	p := make([]byte, 10*plen)
+	for i := range p {
+		p[i] = byte(i * 7)
+	}
+	expect := newPieceWriter(plen)
+	expect.Write(p)
+	expect.Close()
+
+	var batched int
+	w, err := NewWriterSingle(plen, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.SetHash(func() hash.Hash { return batchSHA1{sha1.New(), &batched} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(p[:plen/2])
+	w.Write(p[plen/2:])
+	err = w.SetHash(sha256.New)
+	if err == nil {
+		t.Errorf("SetHash succeeded after writing")
+	}
+	meta, err := w.Metainfo("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(meta.Info.Pieces, expect.Pieces()) {
+		t.Errorf("pieces do not match")
+	}
+	if batched != 8 {
+		t.Errorf("%d pieces hashed in batches (expected 8)", batched)
+	}
+
+	w, _ = NewWriterSingle(plen, "test")
+	w.SetHash(sha256.New)
+	w.Write(p)
+	meta, _ = w.Metainfo("", "")
+	if len(meta.Info.Pieces) != 10*sha256.Size {
+		t.Errorf("%d bytes of SHA-256 pieces (expected %d)", len(meta.Info.Pieces), 10*sha256.Size)
+	}
+}
+
+func TestWriter_SetSimilar(t *testing.T) {
+	w, err := NewWriterSingle(16, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := []InfoHash{{1, 2, 3}}
+	w.SetSimilar(hashes...)
+	hashes[0][0] = 2
+	w.SetCollections("a", "b")
+	w.Write([]byte("hello"))
+	meta, err := w.Metainfo("", "http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Info.Similar) != 1 || meta.Info.Similar[0] != (InfoHash{1, 2, 3}) {
+		t.Errorf("similar %v", meta.Info.Similar)
+	}
+	if len(meta.Info.Collections) != 2 || meta.Info.Collections[1] != "b" {
+		t.Errorf("collections %q", meta.Info.Collections)
+	}
+	err = meta.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriter_Pad(t *testing.T) {
+	w, err := NewWriter(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Pad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Open("a")
+	w.Write([]byte("0123456789"))
+	err = w.Pad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("wrote to a padding file")
+	}
+	w.Open("b")
+	w.Write([]byte("0123456789abcdef"))
+	err = w.Pad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Open("c")
+	w.Write([]byte("z"))
+	meta, err := w.Metainfo("test", "http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range meta.Info.Files {
+		path := strings.Join(file.Path, "/")
+		if file.IsPadding() {
+			path += fmt.Sprintf(" (padding %d)", file.Length)
+		}
+		paths = append(paths, path)
+	}
+	expect := []string{"a", ".pad/6 (padding 6)", "b", "c"}
+	if !reflect.DeepEqual(paths, expect) {
+		t.Errorf("files %q (expected %q)", paths, expect)
+	}
+
+	content := "0123456789\x00\x00\x00\x00\x00\x000123456789abcdefz"
+	pw := newPieceWriter(16)
+	pw.Write([]byte(content))
+	pw.Close()
+	if !bytes.Equal(meta.Info.Pieces, pw.Pieces()) {
+		t.Errorf("pieces do not match padded content")
+	}
+	err = meta.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriter_AddReader(t *testing.T) {
+	content := map[string]string{
+		"/a": strings.Repeat("abc", 100),
+		"/b": "xyz",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	w, err := NewWriter(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mirror bytes.Buffer
+	for _, name := range []string{"a", "b"} {
+		resp, err := http.Get(srv.URL + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := w.AddReader(io.TeeReader(resp.Body, &mirror), "dir", name)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(content["/"+name])) {
+			t.Errorf("%s: added %d bytes", name, n)
+		}
+	}
+	meta, err := w.Metainfo("test", "http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw := newPieceWriter(64)
+	pw.Write([]byte(content["/a"] + content["/b"]))
+	pw.Close()
+	if !bytes.Equal(meta.Info.Pieces, pw.Pieces()) {
+		t.Errorf("pieces do not match content")
+	}
+	if mirror.String() != content["/a"]+content["/b"] {
+		t.Errorf("mirrored %d bytes", mirror.Len())
+	}
+	if len(meta.Info.Files) != 2 || meta.Info.Files[1].Length != 3 {
+		t.Errorf("files %v", meta.Info.Files)
+	}
+
+	single, err := NewWriterSingle(64, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = single.AddReader(strings.NewReader("x"), "y")
+	if err == nil {
+		t.Errorf("added a file to a single-file writer")
+	}
+	_, err = single.AddReader(strings.NewReader("x"))
+	if err != nil {
+		t.Error(err)
+	}
 }
@@ -0,0 +1,101 @@
+package peerwire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// DefaultMaxLength is the default limit on the length of received messages.
+// It allows 16 KiB blocks and the bitfields of torrents with a million
+// pieces.
+const DefaultMaxLength = 1 << 20
+
+// Conn is a peer wire connection.  A Conn may be read from and written to
+// concurrently, but multiple goroutines must not read, or write, at once.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	buf  []byte
+
+	// MaxLength limits the length of received messages.  If zero
+	// DefaultMaxLength is used.
+	MaxLength uint32
+}
+
+// NewConn returns a Conn exchanging messages over conn.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// NetConn returns the underlying network connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// Close closes the underlying network connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// WriteHandshake sends h to the peer.
+func (c *Conn) WriteHandshake(h *Handshake) error {
+	p, err := h.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(p)
+	return err
+}
+
+// ReadHandshake receives the peer's handshake.
+func (c *Conn) ReadHandshake() (*Handshake, error) {
+	return readHandshake(c.r)
+}
+
+// WriteMessage sends m to the peer.
+func (c *Conn) WriteMessage(m *Message) error {
+	p, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(p)
+	return err
+}
+
+// ReadMessage receives the next message from the peer.  Byte slices of the
+// message are only valid until the next call to ReadMessage.
+func (c *Conn) ReadMessage() (*Message, error) {
+	var prefix [4]byte
+	_, err := io.ReadFull(c.r, prefix[:])
+	if err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(prefix[:])
+	max := c.MaxLength
+	if max == 0 {
+		max = DefaultMaxLength
+	}
+	if n > max {
+		return nil, fmt.Errorf("message length %d exceeds limit %d", n, max)
+	}
+	if cap(c.buf) < int(n) {
+		c.buf = make([]byte, n)
+	}
+	p := c.buf[:n]
+	_, err = io.ReadFull(c.r, p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := new(Message)
+	err = m.UnmarshalBinary(p)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
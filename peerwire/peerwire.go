@@ -0,0 +1,255 @@
+/*
+Package peerwire implements the BitTorrent peer wire protocol (BEP 3): the
+handshake and the framing of the messages peers exchange.
+
+This package API is unstable and may change without notice.
+*/
+package peerwire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Protocol is the protocol string sent in handshakes.
+const Protocol = "BitTorrent protocol"
+
+// handshakeLen is the length of an encoded handshake.
+const handshakeLen = 1 + len(Protocol) + 8 + 20 + 20
+
+// Feature is a bit of the reserved bytes of a handshake, numbered from the
+// least significant bit of the last byte.
+type Feature uint
+
+// Features commonly signaled in the reserved bytes.
+const (
+	DHT               Feature = 0  // BEP 5
+	Fast              Feature = 2  // BEP 6
+	ExtensionProtocol Feature = 20 // BEP 10
+)
+
+// Reserved holds the reserved bytes of a handshake.
+type Reserved [8]byte
+
+// Has returns true if feature f is set.
+func (r Reserved) Has(f Feature) bool {
+	return f < 64 && r[7-f/8]&(1<<(f%8)) != 0
+}
+
+// Set sets feature f.
+func (r *Reserved) Set(f Feature) {
+	if f < 64 {
+		r[7-f/8] |= 1 << (f % 8)
+	}
+}
+
+// Handshake is the first message sent by each peer of a connection.
+type Handshake struct {
+	Reserved Reserved
+	InfoHash [20]byte
+	PeerID   [20]byte
+}
+
+// MarshalBinary encodes h.
+func (h *Handshake) MarshalBinary() ([]byte, error) {
+	p := make([]byte, 0, handshakeLen)
+	p = append(p, byte(len(Protocol)))
+	p = append(p, Protocol...)
+	p = append(p, h.Reserved[:]...)
+	p = append(p, h.InfoHash[:]...)
+	p = append(p, h.PeerID[:]...)
+	return p, nil
+}
+
+// UnmarshalBinary decodes a handshake into h.
+func (h *Handshake) UnmarshalBinary(p []byte) error {
+	if len(p) != handshakeLen {
+		return fmt.Errorf("invalid handshake length %d", len(p))
+	}
+	if int(p[0]) != len(Protocol) || string(p[1:1+len(Protocol)]) != Protocol {
+		return fmt.Errorf("unknown protocol")
+	}
+	p = p[1+len(Protocol):]
+	copy(h.Reserved[:], p[:8])
+	copy(h.InfoHash[:], p[8:28])
+	copy(h.PeerID[:], p[28:])
+	return nil
+}
+
+// MessageType identifies the type of a message.
+type MessageType uint8
+
+// Message types of BEP 3, and of BEP 5 and BEP 10 whose payloads are not
+// interpreted.
+const (
+	Choke         MessageType = 0
+	Unchoke       MessageType = 1
+	Interested    MessageType = 2
+	NotInterested MessageType = 3
+	Have          MessageType = 4
+	Bitfield      MessageType = 5
+	Request       MessageType = 6
+	Piece         MessageType = 7
+	Cancel        MessageType = 8
+	Port          MessageType = 9
+	Extended      MessageType = 20
+)
+
+var messageNames = map[MessageType]string{
+	Choke:         "choke",
+	Unchoke:       "unchoke",
+	Interested:    "interested",
+	NotInterested: "not interested",
+	Have:          "have",
+	Bitfield:      "bitfield",
+	Request:       "request",
+	Piece:         "piece",
+	Cancel:        "cancel",
+	Port:          "port",
+	Extended:      "extended",
+}
+
+func (t MessageType) String() string {
+	if name, ok := messageNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("message type %d", uint8(t))
+}
+
+// Message is a message following the handshake.  Which fields are used
+// depends on Type:
+//
+//	Have             Index
+//	Bitfield         Bitfield
+//	Request, Cancel  Index, Begin, Length
+//	Piece            Index, Begin, Block
+//	Port             Port
+//
+// Other types carry an uninterpreted Payload.  A keep-alive message has
+// KeepAlive set and no type.
+type Message struct {
+	KeepAlive bool
+	Type      MessageType
+	Index     uint32
+	Begin     uint32
+	Length    uint32
+	Bitfield  []byte
+	Block     []byte
+	Port      uint16
+	Payload   []byte
+}
+
+// MarshalBinary encodes m with its length prefix.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if m.KeepAlive {
+		return make([]byte, 4), nil
+	}
+	p := make([]byte, 5, 5+len(m.Bitfield)+len(m.Block)+len(m.Payload)+12)
+	p[4] = byte(m.Type)
+	switch m.Type {
+	case Choke, Unchoke, Interested, NotInterested:
+	case Have:
+		p = appendUint32(p, m.Index)
+	case Bitfield:
+		p = append(p, m.Bitfield...)
+	case Request, Cancel:
+		p = appendUint32(p, m.Index)
+		p = appendUint32(p, m.Begin)
+		p = appendUint32(p, m.Length)
+	case Piece:
+		p = appendUint32(p, m.Index)
+		p = appendUint32(p, m.Begin)
+		p = append(p, m.Block...)
+	case Port:
+		p = append(p, byte(m.Port>>8), byte(m.Port))
+	default:
+		p = append(p, m.Payload...)
+	}
+	binary.BigEndian.PutUint32(p, uint32(len(p)-4))
+	return p, nil
+}
+
+// UnmarshalBinary decodes a message without its length prefix into m.
+// Byte slices of m reference p.
+func (m *Message) UnmarshalBinary(p []byte) error {
+	*m = Message{}
+	if len(p) == 0 {
+		m.KeepAlive = true
+		return nil
+	}
+	m.Type = MessageType(p[0])
+	p = p[1:]
+	var size int // the required payload size, or -1 for any
+	switch m.Type {
+	case Choke, Unchoke, Interested, NotInterested:
+		size = 0
+	case Have:
+		size = 4
+	case Request, Cancel:
+		size = 12
+	case Port:
+		size = 2
+	case Piece:
+		if len(p) < 8 {
+			return fmt.Errorf("short piece message")
+		}
+		size = -1
+	default:
+		size = -1
+	}
+	if size >= 0 && len(p) != size {
+		return fmt.Errorf("invalid %v message length %d", m.Type, len(p))
+	}
+	switch m.Type {
+	case Have:
+		m.Index = binary.BigEndian.Uint32(p)
+	case Bitfield:
+		m.Bitfield = p
+	case Request, Cancel:
+		m.Index = binary.BigEndian.Uint32(p)
+		m.Begin = binary.BigEndian.Uint32(p[4:])
+		m.Length = binary.BigEndian.Uint32(p[8:])
+	case Piece:
+		m.Index = binary.BigEndian.Uint32(p)
+		m.Begin = binary.BigEndian.Uint32(p[4:])
+		m.Block = p[8:]
+	case Port:
+		m.Port = binary.BigEndian.Uint16(p)
+	case Choke, Unchoke, Interested, NotInterested:
+	default:
+		m.Payload = p
+	}
+	return nil
+}
+
+func appendUint32(p []byte, x uint32) []byte {
+	return append(p, byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+}
+
+// readHandshake reads a handshake from r.  The protocol string length is
+// checked before the rest of the handshake is read, so connections from
+// other protocols fail quickly.
+func readHandshake(r io.Reader) (*Handshake, error) {
+	p := make([]byte, handshakeLen)
+	_, err := io.ReadFull(r, p[:1])
+	if err != nil {
+		return nil, err
+	}
+	if int(p[0]) != len(Protocol) {
+		return nil, fmt.Errorf("unknown protocol")
+	}
+	_, err = io.ReadFull(r, p[1:])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := new(Handshake)
+	err = h.UnmarshalBinary(p)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
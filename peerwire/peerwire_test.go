@@ -0,0 +1,137 @@
+package peerwire
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestReserved(t *testing.T) {
+	var r Reserved
+	r.Set(ExtensionProtocol)
+	r.Set(DHT)
+	if r != (Reserved{0, 0, 0, 0, 0, 0x10, 0, 0x01}) {
+		t.Errorf("reserved %x", r)
+	}
+	if !r.Has(DHT) || !r.Has(ExtensionProtocol) || r.Has(Fast) {
+		t.Errorf("features of %x", r)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	for _, test := range []struct {
+		m   Message
+		enc string
+	}{
+		{Message{KeepAlive: true}, "\x00\x00\x00\x00"},
+		{Message{Type: Interested}, "\x00\x00\x00\x01\x02"},
+		{Message{Type: Have, Index: 258}, "\x00\x00\x00\x05\x04\x00\x00\x01\x02"},
+		{Message{Type: Bitfield, Bitfield: []byte{0xf0}}, "\x00\x00\x00\x02\x05\xf0"},
+		{Message{Type: Request, Index: 1, Begin: 2, Length: 3}, "\x00\x00\x00\x0d\x06\x00\x00\x00\x01\x00\x00\x00\x02\x00\x00\x00\x03"},
+		{Message{Type: Piece, Index: 1, Begin: 2, Block: []byte("abc")}, "\x00\x00\x00\x0c\x07\x00\x00\x00\x01\x00\x00\x00\x02abc"},
+		{Message{Type: Cancel, Index: 1, Begin: 2, Length: 3}, "\x00\x00\x00\x0d\x08\x00\x00\x00\x01\x00\x00\x00\x02\x00\x00\x00\x03"},
+		{Message{Type: Port, Port: 6881}, "\x00\x00\x00\x03\x09\x1a\xe1"},
+		{Message{Type: Extended, Payload: []byte("\x00de")}, "\x00\x00\x00\x04\x14\x00de"},
+	} {
+		p, err := test.m.MarshalBinary()
+		if err != nil {
+			t.Errorf("%v: %v", test.m.Type, err)
+			continue
+		}
+		if string(p) != test.enc {
+			t.Errorf("%v: encoded %q (expected %q)", test.m.Type, p, test.enc)
+		}
+		var m Message
+		err = m.UnmarshalBinary(p[4:])
+		if err != nil {
+			t.Errorf("%v: %v", test.m.Type, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, test.m) {
+			t.Errorf("decoded %+v (expected %+v)", m, test.m)
+		}
+	}
+}
+
+func TestMessage_invalid(t *testing.T) {
+	for _, p := range []string{
+		"\x00x",
+		"\x04\x00\x00\x01",
+		"\x06\x00\x00\x00\x01\x00\x00\x00\x02",
+		"\x07\x00\x00\x00\x01",
+		"\x09\x1a",
+	} {
+		var m Message
+		err := m.UnmarshalBinary([]byte(p))
+		if err == nil {
+			t.Errorf("decoded %q", p)
+		}
+	}
+}
+
+func TestConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	a, b := NewConn(c1), NewConn(c2)
+	defer a.Close()
+	defer b.Close()
+
+	h := &Handshake{}
+	h.Reserved.Set(ExtensionProtocol)
+	copy(h.InfoHash[:], bytes.Repeat([]byte{0xab}, 20))
+	copy(h.PeerID[:], "-BT0000-000000000000")
+	msgs := []*Message{
+		{Type: Bitfield, Bitfield: []byte{0xff, 0x80}},
+		{KeepAlive: true},
+		{Type: Piece, Index: 7, Begin: 16384, Block: bytes.Repeat([]byte{1}, 16384)},
+	}
+	errc := make(chan error, 1)
+	go func() {
+		err := a.WriteHandshake(h)
+		for _, m := range msgs {
+			if err != nil {
+				break
+			}
+			err = a.WriteMessage(m)
+		}
+		errc <- err
+	}()
+
+	got, err := b.ReadHandshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *h {
+		t.Errorf("handshake %+v (expected %+v)", got, h)
+	}
+	for i, expect := range msgs {
+		m, err := b.ReadMessage()
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(m, expect) {
+			t.Errorf("message %d: %v (expected %v)", i, m.Type, expect.Type)
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	b.MaxLength = 16
+	go a.WriteMessage(&Message{Type: Bitfield, Bitfield: make([]byte, 16)})
+	_, err = b.ReadMessage()
+	if err == nil {
+		t.Errorf("read a message exceeding the length limit")
+	}
+}
+
+func TestConn_ReadHandshake(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	go c1.Write([]byte("\x04HTTP/1.1 200 OK"))
+	_, err := NewConn(c2).ReadHandshake()
+	if err == nil {
+		t.Errorf("read a handshake for another protocol")
+	}
+}
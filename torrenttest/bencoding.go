@@ -0,0 +1,138 @@
+package torrenttest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// hugeStringLength is the length of the occasional huge string produced by
+// RandomBencoding.
+const hugeStringLength = 1 << 20
+
+// edgeIntegers are integers likely to expose parsing and overflow bugs.
+var edgeIntegers = []int64{
+	0, 1, -1, 9, 10, -10,
+	math.MaxInt8, math.MinInt8, math.MaxUint8,
+	math.MaxInt16, math.MinInt16, math.MaxUint16,
+	math.MaxInt32, math.MinInt32, math.MaxUint32,
+	math.MaxInt64, math.MinInt64,
+	math.MaxInt64 - 1, math.MinInt64 + 1,
+}
+
+// RandomBencoding returns a random, valid bencoded value using r.  Lists and
+// dictionaries are nested at most maxDepth levels.  Dictionary keys are
+// unique and sorted.  Integers fit in an int64.
+func RandomBencoding(r *rand.Rand, maxDepth int) []byte {
+	var buf bytes.Buffer
+	writeRandom(&buf, r, maxDepth)
+	return buf.Bytes()
+}
+
+func writeRandom(buf *bytes.Buffer, r *rand.Rand, depth int) {
+	kinds := 2
+	if depth > 0 {
+		kinds = 4
+	}
+	switch r.Intn(kinds) {
+	case 0:
+		buf.WriteString("i")
+		buf.WriteString(strconv.FormatInt(randomInteger(r), 10))
+		buf.WriteString("e")
+	case 1:
+		writeString(buf, randomString(r))
+	case 2:
+		buf.WriteString("l")
+		for n := r.Intn(8); n > 0; n-- {
+			writeRandom(buf, r, depth-1)
+		}
+		buf.WriteString("e")
+	case 3:
+		keys := make(map[string]bool)
+		for n := r.Intn(8); n > 0; n-- {
+			keys[randomKey(r)] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		buf.WriteString("d")
+		for _, k := range sorted {
+			writeString(buf, k)
+			writeRandom(buf, r, depth-1)
+		}
+		buf.WriteString("e")
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteString(":")
+	buf.WriteString(s)
+}
+
+func randomInteger(r *rand.Rand) int64 {
+	switch r.Intn(3) {
+	case 0:
+		return edgeIntegers[r.Intn(len(edgeIntegers))]
+	case 1:
+		return int64(r.Intn(2000) - 1000)
+	}
+	n := r.Int63()
+	if r.Intn(2) == 0 {
+		n = -n
+	}
+	return n
+}
+
+func randomString(r *rand.Rand) string {
+	var n int
+	switch r.Intn(20) {
+	case 0:
+		n = 0
+	case 1:
+		n = hugeStringLength
+	default:
+		n = r.Intn(64)
+	}
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(r.Intn(256))
+	}
+	return string(p)
+}
+
+// randomKey returns a short printable dictionary key.
+func randomKey(r *rand.Rand) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz -"
+	p := make([]byte, r.Intn(12))
+	for i := range p {
+		p[i] = alphabet[r.Intn(len(alphabet))]
+	}
+	return string(p)
+}
+
+// WriteCorpus writes n documents from RandomBencoding, seeded by seed, to
+// dir as files named by their index.  Dir is created if necessary.
+func WriteCorpus(dir string, seed int64, n, maxDepth int) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < n; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%d.bencode", i))
+		err = ioutil.WriteFile(path, RandomBencoding(r, maxDepth), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
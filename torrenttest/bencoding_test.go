@@ -0,0 +1,45 @@
+package torrenttest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/bmatsuo/torrent/bencoding"
+)
+
+func TestRandomBencoding(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		p := RandomBencoding(r, 4)
+		var v interface{}
+		err := bencoding.Unmarshal(p, &v)
+		if err != nil {
+			t.Fatalf("document %d: %v", i, err)
+		}
+		q, err := bencoding.Marshal(v)
+		if err != nil {
+			t.Fatalf("document %d: %v", i, err)
+		}
+		if !bytes.Equal(p, q) {
+			t.Fatalf("document %d does not round trip", i)
+		}
+	}
+}
+
+func TestWriteCorpus(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "corpus")
+	err := WriteCorpus(dir, 1, 5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 5 {
+		t.Errorf("%d files in corpus (expected 5)", len(files))
+	}
+}
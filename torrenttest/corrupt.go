@@ -0,0 +1,77 @@
+package torrenttest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// CorruptPieces flips the first byte of each given piece of info's content
+// stored in dir, so that exactly those pieces fail verification.  Dir is the
+// directory containing the single file or the torrent's content directory.
+func CorruptPieces(dir string, info *metainfo.Info, pieces ...int) error {
+	type dataFile struct {
+		path   string
+		offset int64
+		length int64
+	}
+	var files []dataFile
+	var total int64
+	if info.SingleFileMode() {
+		files = append(files, dataFile{filepath.Join(dir, info.Name), 0, info.Length})
+		total = info.Length
+	}
+	for _, file := range info.Files {
+		path := filepath.Join(append([]string{dir, info.Name}, file.Path...)...)
+		files = append(files, dataFile{path, total, file.Length})
+		total += file.Length
+	}
+
+	npieces := len(info.Pieces) / 20
+	for _, i := range pieces {
+		if i < 0 || i >= npieces {
+			return fmt.Errorf("piece %d out of range", i)
+		}
+		off := int64(i) * info.PieceLength
+		for _, file := range files {
+			if off >= file.offset && off < file.offset+file.length {
+				err := flipByte(file.path, off-file.offset)
+				if err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func flipByte(path string, off int64) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 1)
+	_, err = f.ReadAt(b, off)
+	if err == nil {
+		b[0] ^= 0xff
+		_, err = f.WriteAt(b, off)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// CorruptPieces flips a byte in each of the given pieces of tree.  Errors are
+// reported with t.Fatal.
+func (tree *Tree) CorruptPieces(t testing.TB, pieces ...int) {
+	err := CorruptPieces(tree.Dir, &tree.Meta.Info, pieces...)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,41 @@
+package torrenttest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCorruptPieces(t *testing.T) {
+	for _, c := range []*Content{
+		Generate(1, 16, 100),
+		Generate(2, 16, 10, 0, 30, 5, 40),
+	} {
+		tree := Materialize(t, c, "http://example.com/announce")
+		tree.CorruptPieces(t, 0, 2, 5)
+
+		// rebuild the content from disk and find the pieces that changed
+		disk := &Content{Name: c.Name, PieceLength: c.PieceLength}
+		for i, path := range tree.Paths {
+			p, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			disk.Files = append(disk.Files, &File{Path: c.Files[i].Path, Data: p})
+		}
+		expect, got := c.Pieces(), disk.Pieces()
+		var bad []int
+		for i := 0; i < len(expect)/20; i++ {
+			if !bytes.Equal(expect[i*20:(i+1)*20], got[i*20:(i+1)*20]) {
+				bad = append(bad, i)
+			}
+		}
+		if len(bad) != 3 || bad[0] != 0 || bad[1] != 2 || bad[2] != 5 {
+			t.Errorf("%s: corrupt pieces %v (expected [0 2 5])", c.Name, bad)
+		}
+	}
+	err := CorruptPieces(t.TempDir(), &Generate(1, 16, 10).Metainfo("").Info, 1)
+	if err == nil {
+		t.Errorf("expected error for piece out of range")
+	}
+}
@@ -0,0 +1,158 @@
+package torrenttest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// Golden is a torrent fixture loaded from test data.
+type Golden struct {
+	Name string // base name of the file
+	Path string
+	Raw  []byte
+	Meta *metainfo.Metainfo
+}
+
+// LoadGolden reads and decodes the torrent files matching the glob pattern.
+// The test fails if no files match or a file cannot be decoded.
+func LoadGolden(t testing.TB, pattern string) []*Golden {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no golden files match %q", pattern)
+	}
+	var golden []*Golden
+	for _, path := range paths {
+		p, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta := new(metainfo.Metainfo)
+		err = bencoding.Unmarshal(p, meta)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		golden = append(golden, &Golden{filepath.Base(path), path, p, meta})
+	}
+	return golden
+}
+
+// Normalize returns a copy of meta without fields that vary between
+// otherwise identical torrents (creation date and creator), so generated
+// torrents can be compared with fixtures.
+func Normalize(meta *metainfo.Metainfo) *metainfo.Metainfo {
+	norm := *meta
+	norm.CreationDate = 0
+	norm.CreatedBy = ""
+	return &norm
+}
+
+// CompareMetainfo reports each difference between got and want as a test
+// error prefixed by name.
+func CompareMetainfo(t testing.TB, name string, got, want *metainfo.Metainfo) {
+	for _, d := range Diff(got, want) {
+		t.Errorf("%s: %s", name, d)
+	}
+}
+
+// Diff returns a readable description of each field that differs between a
+// and b.  Nil and empty slices and maps are considered equal.
+func Diff(a, b interface{}) []string {
+	var diffs []string
+	diff(&diffs, "", reflect.ValueOf(a), reflect.ValueOf(b))
+	return diffs
+}
+
+func diff(diffs *[]string, path string, a, b reflect.Value) {
+	report := func(format string, v ...interface{}) {
+		p := path
+		if p == "" {
+			p = "value"
+		}
+		*diffs = append(*diffs, p+": "+fmt.Sprintf(format, v...))
+	}
+	if !a.IsValid() || !b.IsValid() {
+		if a.IsValid() != b.IsValid() {
+			report("%v != %v", a, b)
+		}
+		return
+	}
+	if a.Type() != b.Type() {
+		report("type %v != %v", a.Type(), b.Type())
+		return
+	}
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				report("%v != %v", a, b)
+			}
+			return
+		}
+		diff(diffs, path, a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			f := a.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			diff(diffs, joinPath(path, f.Name), a.Field(i), b.Field(i))
+		}
+	case reflect.Slice:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			if !bytes.Equal(a.Bytes(), b.Bytes()) {
+				report("%s != %s", describeBytes(a.Bytes()), describeBytes(b.Bytes()))
+			}
+			return
+		}
+		if a.Len() != b.Len() {
+			report("length %d != %d", a.Len(), b.Len())
+		}
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			diff(diffs, fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i))
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, k := range append(a.MapKeys(), b.MapKeys()...) {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		var names []string
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			k := keys[name]
+			diff(diffs, fmt.Sprintf("%s[%q]", path, name), a.MapIndex(k), b.MapIndex(k))
+		}
+	default:
+		if a.Interface() != b.Interface() {
+			report("%#v != %#v", a.Interface(), b.Interface())
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// describeBytes abbreviates long byte slices, such as piece hashes.
+func describeBytes(p []byte) string {
+	if len(p) <= 32 {
+		return fmt.Sprintf("%x", p)
+	}
+	return fmt.Sprintf("%x... (%d bytes)", p[:16], len(p))
+}
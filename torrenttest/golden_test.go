@@ -0,0 +1,51 @@
+package torrenttest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+func TestDiff(t *testing.T) {
+	a := &metainfo.Metainfo{Announce: "x", Info: metainfo.Info{
+		Name:   "a",
+		Files:  []metainfo.FileInfo{{Path: []string{"b"}, Length: 1}},
+		Pieces: make([]byte, 40),
+	}}
+	b := &metainfo.Metainfo{Announce: "y", Info: metainfo.Info{
+		Name:   "a",
+		Files:  []metainfo.FileInfo{{Path: []string{"c"}, Length: 1}, {}},
+		Pieces: make([]byte, 20),
+	}}
+	expect := []string{
+		`Info.Files: length 1 != 2`,
+		`Info.Files[0].Path[0]: "b" != "c"`,
+		`Info.Pieces: ` + describeBytes(a.Info.Pieces) + ` != ` + describeBytes(b.Info.Pieces),
+		`Announce: "x" != "y"`,
+	}
+	diffs := Diff(a, b)
+	if !reflect.DeepEqual(diffs, expect) {
+		t.Errorf("diff %q (expected %q)", diffs, expect)
+	}
+	if diffs := Diff(a, a); len(diffs) != 0 {
+		t.Errorf("diff of equal values %q", diffs)
+	}
+	if diffs := Diff(map[string]int{"a": 1}, map[string]int{"b": 1}); len(diffs) != 2 {
+		t.Errorf("map diff %q", diffs)
+	}
+}
+
+func TestLoadGolden(t *testing.T) {
+	golden := LoadGolden(t, filepath.Join("..", "metainfo", "test", "torrents", "*.torrent"))
+	for _, g := range golden {
+		if g.Meta.Info.Name == "" || len(g.Raw) == 0 {
+			t.Errorf("%s: not loaded", g.Name)
+		}
+		norm := Normalize(g.Meta)
+		if norm.CreationDate != 0 || norm.Info.Name != g.Meta.Info.Name {
+			t.Errorf("%s: bad normalization", g.Name)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package torrenttest
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// Mutation is a corrupted variant of a valid torrent.
+type Mutation struct {
+	Name    string
+	Torrent []byte
+
+	// Lenient is true if the corruption violates the specification but
+	// decoders commonly accept it (e.g. unsorted dictionary keys).
+	Lenient bool
+}
+
+// Mutate returns systematically corrupted variants of the valid bencoded
+// torrent p.  Each variant should fail to decode or fail
+// metainfo.Metainfo.Validate, unless it is lenient.
+func Mutate(p []byte) ([]Mutation, error) {
+	var meta metainfo.Metainfo
+	err := bencoding.Unmarshal(p, &meta)
+	if err != nil {
+		return nil, err
+	}
+	var top map[string]bencoding.RawMessage
+	err = bencoding.Unmarshal(p, &top)
+	if err != nil {
+		return nil, err
+	}
+
+	var ms []Mutation
+	add := func(name string, p []byte, lenient bool) {
+		ms = append(ms, Mutation{name, p, lenient})
+	}
+	for _, n := range []int{1, len(p) / 2, len(p) - 1} {
+		add("truncated", p[:n], false)
+	}
+	add("trailing bytes", append(append([]byte(nil), p...), 'x'), false)
+	add("unsorted keys", encodeUnsorted(top), true)
+	delete(top, "info")
+	q, err := bencoding.Marshal(rawDict(top))
+	if err != nil {
+		return nil, err
+	}
+	add("missing info", q, false)
+
+	for _, m := range []struct {
+		name   string
+		mutate func(info *metainfo.Info)
+	}{
+		{"zero piece length", func(info *metainfo.Info) { info.PieceLength = 0 }},
+		{"negative piece length", func(info *metainfo.Info) { info.PieceLength = -info.PieceLength }},
+		{"extra piece", func(info *metainfo.Info) { info.Pieces = append(info.Pieces, make([]byte, 20)...) }},
+		{"short pieces", func(info *metainfo.Info) { info.Pieces = info.Pieces[:len(info.Pieces)-1] }},
+		{"missing piece", func(info *metainfo.Info) { info.Pieces = info.Pieces[:len(info.Pieces)-20] }},
+		{"empty name", func(info *metainfo.Info) { info.Name = "" }},
+		{"negative length", func(info *metainfo.Info) {
+			if info.SingleFileMode() {
+				info.Length = -info.Length - 1
+			} else {
+				info.Files[0].Length = -info.Files[0].Length - 1
+			}
+		}},
+		{"path traversal", func(info *metainfo.Info) {
+			if info.SingleFileMode() {
+				info.Name = ".."
+			} else {
+				info.Files[0].Path = append([]string{".."}, info.Files[0].Path...)
+			}
+		}},
+		{"path separator", func(info *metainfo.Info) {
+			if info.SingleFileMode() {
+				info.Name = "../" + info.Name
+			} else {
+				info.Files[0].Path = []string{"/etc/passwd"}
+			}
+		}},
+		{"empty path", func(info *metainfo.Info) {
+			if info.SingleFileMode() {
+				info.Name = ""
+			} else {
+				info.Files[0].Path = nil
+			}
+		}},
+		{"symlink traversal", func(info *metainfo.Info) { addSymlink(info, "..", "..", "etc", "passwd") }},
+		{"absolute symlink", func(info *metainfo.Info) { addSymlink(info, "/etc/passwd") }},
+	} {
+		mutated := meta
+		mutated.Info.Files = append([]metainfo.FileInfo(nil), meta.Info.Files...)
+		m.mutate(&mutated.Info)
+		q, err := bencoding.Marshal(mutated)
+		if err != nil {
+			return nil, err
+		}
+		add(m.name, q, false)
+	}
+	return ms, nil
+}
+
+// addSymlink appends a BEP 47 symlink to target to info.  A single-file info
+// is converted to an equivalent multi-file info first.
+func addSymlink(info *metainfo.Info, target ...string) {
+	if info.SingleFileMode() {
+		info.Files = []metainfo.FileInfo{{Path: []string{info.Name}, Length: info.Length}}
+		info.Length = 0
+		info.MD5Sum = ""
+	}
+	info.Files = append(info.Files, metainfo.FileInfo{
+		Path:        []string{"link"},
+		Attr:        "l",
+		SymlinkPath: target,
+	})
+}
+
+// rawDict converts a map of encoded values to a map the encoder accepts.
+func rawDict(m map[string]bencoding.RawMessage) map[string]interface{} {
+	d := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		d[k] = v
+	}
+	return d
+}
+
+// encodeUnsorted encodes m with its keys in reverse order.
+func encodeUnsorted(m map[string]bencoding.RawMessage) []byte {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	var buf bytes.Buffer
+	buf.WriteByte('d')
+	for _, k := range keys {
+		p, _ := bencoding.Marshal(k)
+		buf.Write(p)
+		buf.Write(m[k])
+	}
+	buf.WriteByte('e')
+	return buf.Bytes()
+}
@@ -0,0 +1,37 @@
+package torrenttest
+
+import (
+	"testing"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+func TestMutate(t *testing.T) {
+	for _, c := range []*Content{
+		Generate(1, 16, 40),
+		Generate(2, 16, 0, 20, 5),
+	} {
+		p, err := bencoding.Marshal(c.Metainfo("http://example.com/announce"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms, err := Mutate(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range ms {
+			var meta metainfo.Metainfo
+			err := bencoding.Unmarshal(m.Torrent, &meta)
+			if err == nil {
+				err = meta.Validate()
+			}
+			if err == nil && !m.Lenient {
+				t.Errorf("%s: %s mutation is valid", c.Name, m.Name)
+			}
+			if err != nil && m.Lenient {
+				t.Errorf("%s: %s mutation is invalid: %v", c.Name, m.Name, err)
+			}
+		}
+	}
+}
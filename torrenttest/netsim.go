@@ -0,0 +1,82 @@
+package torrenttest
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrReset is returned by a simulated connection that was reset.
+var ErrReset = errors.New("torrenttest: connection reset")
+
+// Conditions describe degraded network conditions for SimulateConn.
+type Conditions struct {
+	Latency   time.Duration // delay added to each write
+	Jitter    time.Duration // maximum random delay added to Latency
+	Bandwidth int           // bytes per second written; zero is unlimited
+	ResetRate float64       // probability a read or write resets the connection
+	Seed      int64         // seed for jitter and resets
+}
+
+// SimulateConn wraps conn so that writes are delayed and throttled and reads
+// and writes randomly reset the connection according to cond.  Delays are
+// applied by blocking the writer, so a peer observes data arriving late and
+// at a limited rate.  After a reset conn is closed and every operation
+// returns ErrReset.
+func SimulateConn(conn net.Conn, cond Conditions) net.Conn {
+	return &simConn{
+		Conn: conn,
+		cond: cond,
+		rand: rand.New(rand.NewSource(cond.Seed)),
+	}
+}
+
+type simConn struct {
+	net.Conn
+	cond Conditions
+
+	mut   sync.Mutex // guards rand and reset
+	rand  *rand.Rand
+	reset bool
+}
+
+// fail decides whether the next operation resets the connection.
+func (c *simConn) fail() bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if !c.reset && c.cond.ResetRate > 0 && c.rand.Float64() < c.cond.ResetRate {
+		c.reset = true
+		c.Conn.Close()
+	}
+	return c.reset
+}
+
+func (c *simConn) delay(n int) time.Duration {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	d := c.cond.Latency
+	if c.cond.Jitter > 0 {
+		d += time.Duration(c.rand.Int63n(int64(c.cond.Jitter)))
+	}
+	if c.cond.Bandwidth > 0 {
+		d += time.Duration(n) * time.Second / time.Duration(c.cond.Bandwidth)
+	}
+	return d
+}
+
+func (c *simConn) Read(p []byte) (int, error) {
+	if c.fail() {
+		return 0, ErrReset
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *simConn) Write(p []byte) (int, error) {
+	if c.fail() {
+		return 0, ErrReset
+	}
+	time.Sleep(c.delay(len(p)))
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,50 @@
+package torrenttest
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSimulateConn(t *testing.T) {
+	for _, test := range []struct {
+		cond Conditions
+		min  time.Duration
+	}{
+		{Conditions{Latency: 20 * time.Millisecond}, 20 * time.Millisecond},
+		{Conditions{Latency: 10 * time.Millisecond, Jitter: 10 * time.Millisecond}, 10 * time.Millisecond},
+		{Conditions{Bandwidth: 10000}, 50 * time.Millisecond},
+	} {
+		a, b := net.Pipe()
+		go io.Copy(ioutil.Discard, b)
+		conn := SimulateConn(a, test.cond)
+		start := time.Now()
+		_, err := conn.Write(make([]byte, 500))
+		elapsed := time.Since(start)
+		if err != nil {
+			t.Errorf("%+v: %v", test.cond, err)
+		}
+		if elapsed < test.min {
+			t.Errorf("%+v: write took %v (expected at least %v)", test.cond, elapsed, test.min)
+		}
+		conn.Close()
+		b.Close()
+	}
+}
+
+func TestSimulateConn_reset(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	conn := SimulateConn(a, Conditions{ResetRate: 1})
+	if _, err := conn.Write([]byte("x")); err != ErrReset {
+		t.Errorf("write error %v (expected reset)", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != ErrReset {
+		t.Errorf("read error %v (expected reset)", err)
+	}
+	if _, err := b.Read(make([]byte, 1)); err == nil {
+		t.Errorf("peer read succeeded after reset")
+	}
+}
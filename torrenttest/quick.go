@@ -0,0 +1,174 @@
+package torrenttest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// nameRunes are the characters of generated names.  They include multi-byte
+// UTF-8 sequences but no path separators.
+var nameRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 ._-äöü日本語")
+
+// QuickInfo is a valid metainfo.Info that implements quick.Generator.
+type QuickInfo struct {
+	metainfo.Info
+}
+
+// Generate implements quick.Generator.
+func (QuickInfo) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(QuickInfo{RandomInfo(r, size)})
+}
+
+// QuickMetainfo is a valid metainfo.Metainfo that implements
+// quick.Generator.
+type QuickMetainfo struct {
+	metainfo.Metainfo
+}
+
+// Generate implements quick.Generator.
+func (QuickMetainfo) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(QuickMetainfo{RandomMetainfo(r, size)})
+}
+
+// RandomInfo returns a random Info that passes Validate.  Size bounds the
+// number of files and pieces.
+func RandomInfo(r *rand.Rand, size int) metainfo.Info {
+	if size < 1 {
+		size = 1
+	}
+	info := metainfo.Info{
+		Name:        randomName(r),
+		PieceLength: 1 << uint(14+r.Intn(9)),
+		Private:     r.Intn(2) == 0,
+	}
+	if r.Intn(3) == 0 {
+		info.Source = randomName(r)
+	}
+	if r.Intn(4) == 0 {
+		for n := 1 + r.Intn(3); n > 0; n-- {
+			var hash metainfo.InfoHash
+			r.Read(hash[:])
+			info.Similar = append(info.Similar, hash)
+		}
+	}
+	if r.Intn(4) == 0 {
+		for n := 1 + r.Intn(3); n > 0; n-- {
+			info.Collections = append(info.Collections, randomName(r))
+		}
+	}
+	var total int64
+	if r.Intn(2) == 0 {
+		info.Length = r.Int63n(int64(size) * info.PieceLength)
+		total = info.Length
+		if r.Intn(2) == 0 {
+			info.MD5Sum = randomHex(r, 16)
+		}
+	} else {
+		for n := 1 + r.Intn(size); n > 0; n-- {
+			file := metainfo.FileInfo{Path: randomPath(r)}
+			switch r.Intn(8) {
+			case 0:
+				file.Attr = "l"
+				file.SymlinkPath = randomPath(r)
+			case 1:
+				file.Attr = "x"
+				fallthrough
+			default:
+				file.Length = r.Int63n(info.PieceLength * 2)
+			}
+			if r.Intn(4) == 0 {
+				file.MD5Sum = randomHex(r, 16)
+			}
+			total += file.Length
+			info.Files = append(info.Files, file)
+		}
+	}
+	npieces := (total + info.PieceLength - 1) / info.PieceLength
+	if npieces == 0 {
+		npieces = 1
+	}
+	info.Pieces = make([]byte, npieces*20)
+	r.Read(info.Pieces)
+	return info
+}
+
+// RandomMetainfo returns a random Metainfo that passes Validate.  Size
+// bounds the number of files, pieces, and trackers.
+func RandomMetainfo(r *rand.Rand, size int) metainfo.Metainfo {
+	meta := metainfo.Metainfo{
+		Info:     RandomInfo(r, size),
+		Announce: randomURL(r),
+	}
+	if !meta.Info.Private && r.Intn(4) == 0 {
+		// trackerless
+		meta.Announce = ""
+		for n := r.Intn(3); n > 0; n-- {
+			meta.Nodes = append(meta.Nodes, metainfo.Node{
+				Host: fmt.Sprintf("node%d.example.com", r.Intn(100)),
+				Port: 1 + r.Intn(65535),
+			})
+		}
+	} else if r.Intn(2) == 0 {
+		for n := 1 + r.Intn(size+1); n > 0; n-- {
+			var tier []string
+			for m := 1 + r.Intn(3); m > 0; m-- {
+				tier = append(tier, randomURL(r))
+			}
+			meta.AnnounceList = append(meta.AnnounceList, tier)
+		}
+	}
+	if r.Intn(2) == 0 {
+		meta.CreationDate = 1 + r.Int63n(1<<32)
+	}
+	if r.Intn(2) == 0 {
+		meta.Encoding = "UTF-8"
+	}
+	if r.Intn(2) == 0 {
+		meta.CreatedBy = randomName(r)
+	}
+	if r.Intn(2) == 0 {
+		meta.Comment = randomName(r)
+	}
+	if r.Intn(4) == 0 {
+		for n := 1 + r.Intn(2); n > 0; n-- {
+			meta.URLList = append(meta.URLList, fmt.Sprintf("http://seed%d.example.com/files/", r.Intn(100)))
+		}
+	}
+	return meta
+}
+
+func randomName(r *rand.Rand) string {
+	for {
+		p := make([]rune, 1+r.Intn(16))
+		for i := range p {
+			p[i] = nameRunes[r.Intn(len(nameRunes))]
+		}
+		s := string(p)
+		if s != "." && s != ".." {
+			return s
+		}
+	}
+}
+
+func randomPath(r *rand.Rand) []string {
+	path := make([]string, 1+r.Intn(3))
+	for i := range path {
+		path[i] = randomName(r)
+	}
+	return path
+}
+
+func randomHex(r *rand.Rand, n int) string {
+	p := make([]byte, n)
+	r.Read(p)
+	return fmt.Sprintf("%x", p)
+}
+
+func randomURL(r *rand.Rand) string {
+	schemes := []string{"http", "https", "udp"}
+	return fmt.Sprintf("%s://tracker%d.example.com:%d/announce",
+		schemes[r.Intn(len(schemes))], r.Intn(100), 1+r.Intn(65535))
+}
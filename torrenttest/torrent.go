@@ -0,0 +1,62 @@
+package torrenttest
+
+import (
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// Torrent builds a torrent in memory from file contents using a
+// metainfo.Writer.
+type Torrent struct {
+	Name     string
+	Announce string
+	w        *metainfo.Writer
+	err      error
+}
+
+// NewTorrent returns a multi-file torrent with the given name and piece
+// length.  Files are added with AddFile.
+func NewTorrent(name string, plen int64) *Torrent {
+	w, err := metainfo.NewWriter(plen)
+	return &Torrent{Name: name, w: w, err: err}
+}
+
+// NewTorrentSingle returns a single-file torrent containing data.
+func NewTorrentSingle(name string, plen int64, data []byte) *Torrent {
+	w, err := metainfo.NewWriterSingle(plen, name)
+	t := &Torrent{Name: name, w: w, err: err}
+	if err == nil {
+		_, t.err = w.Write(data)
+	}
+	return t
+}
+
+// AddFile appends a file with the given content and path to t.  Errors are
+// deferred until the torrent is built.
+func (t *Torrent) AddFile(data []byte, path ...string) {
+	if t.err != nil {
+		return
+	}
+	t.err = t.w.Open(path...)
+	if t.err == nil {
+		_, t.err = t.w.Write(data)
+	}
+}
+
+// Metainfo returns the completed torrent.  No files may be added after
+// Metainfo is called.
+func (t *Torrent) Metainfo() (*metainfo.Metainfo, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.w.Metainfo(t.Name, t.Announce)
+}
+
+// Bytes returns the bencoded torrent file.
+func (t *Torrent) Bytes() ([]byte, error) {
+	meta, err := t.Metainfo()
+	if err != nil {
+		return nil, err
+	}
+	return bencoding.Marshal(meta)
+}
@@ -0,0 +1,49 @@
+package torrenttest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+func TestTorrent(t *testing.T) {
+	c := Generate(1, 16, 10, 30)
+	tor := NewTorrent(c.Name, c.PieceLength)
+	tor.Announce = "http://example.com/announce"
+	for _, file := range c.Files {
+		tor.AddFile(file.Data, file.Path...)
+	}
+	p, err := tor.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta metainfo.Metainfo
+	err = bencoding.Unmarshal(p, &meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = meta.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+	expect := c.Metainfo(tor.Announce)
+	if !reflect.DeepEqual(&meta, expect) {
+		t.Errorf("metainfo %+v (expected %+v)", meta, expect)
+	}
+
+	single := NewTorrentSingle("x", 16, []byte("hello"))
+	smeta, err := single.Metainfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smeta.Info.Name != "x" || smeta.Info.Length != 5 || len(smeta.Info.Pieces) != 20 {
+		t.Errorf("single-file info %+v", smeta.Info)
+	}
+	single.AddFile([]byte("more"), "y")
+	_, err = single.Metainfo()
+	if err == nil {
+		t.Errorf("expected error adding a file to a single-file torrent")
+	}
+}
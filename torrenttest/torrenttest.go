@@ -0,0 +1,117 @@
+/*
+Package torrenttest provides utilities for testing code that works with
+torrents.
+
+This package API is unstable and may change without notice.
+*/
+package torrenttest
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"math/rand"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// File is a generated file.
+type File struct {
+	Path []string // path within the torrent; nil in single-file content
+	Data []byte
+}
+
+// Content is generated torrent content.
+type Content struct {
+	Name        string
+	PieceLength int64
+	Files       []*File
+}
+
+// Generate returns pseudo-random content for the given seed, piece length,
+// and file sizes.  The same arguments always produce the same content.  A
+// single size produces single-file content.  Otherwise the files are named
+// "file0", "file1", and so on.
+func Generate(seed, plen int64, sizes ...int64) *Content {
+	r := rand.New(rand.NewSource(seed))
+	c := &Content{
+		Name:        fmt.Sprintf("torrenttest-%d", seed),
+		PieceLength: plen,
+	}
+	for i, size := range sizes {
+		file := &File{Data: make([]byte, size)}
+		for j := range file.Data {
+			file.Data[j] = byte(r.Int63())
+		}
+		if len(sizes) > 1 {
+			file.Path = []string{fmt.Sprintf("file%d", i)}
+		}
+		c.Files = append(c.Files, file)
+	}
+	return c
+}
+
+// SingleFileMode returns true if c is the content of a single-file torrent.
+func (c *Content) SingleFileMode() bool {
+	return len(c.Files) == 1 && c.Files[0].Path == nil
+}
+
+// Length returns the total length of c.
+func (c *Content) Length() int64 {
+	var n int64
+	for _, file := range c.Files {
+		n += int64(len(file.Data))
+	}
+	return n
+}
+
+// Pieces returns the concatenated SHA-1 piece hashes of c.  Like Writer,
+// Pieces hashes empty content as a single empty piece.
+func (c *Content) Pieces() []byte {
+	var pieces []byte
+	h := sha1.New()
+	var n int64
+	for _, file := range c.Files {
+		p := file.Data
+		for len(p) > 0 {
+			k := c.PieceLength - n
+			if k > int64(len(p)) {
+				k = int64(len(p))
+			}
+			h.Write(p[:k])
+			p = p[k:]
+			n += k
+			if n == c.PieceLength {
+				pieces = h.Sum(pieces)
+				h.Reset()
+				n = 0
+			}
+		}
+	}
+	if n > 0 || len(pieces) == 0 {
+		pieces = h.Sum(pieces)
+	}
+	return pieces
+}
+
+// Metainfo returns the metainfo a Writer produces for c.  As with Writer, the
+// md5sum is only set in single-file mode.
+func (c *Content) Metainfo(announce string) *metainfo.Metainfo {
+	info := metainfo.Info{
+		Name:        c.Name,
+		Pieces:      c.Pieces(),
+		PieceLength: c.PieceLength,
+	}
+	if c.SingleFileMode() {
+		info.Length = int64(len(c.Files[0].Data))
+		info.MD5Sum = fmt.Sprintf("%x", md5.Sum(c.Files[0].Data))
+	} else {
+		for _, file := range c.Files {
+			info.Files = append(info.Files, metainfo.FileInfo{
+				Path:   file.Path,
+				Length: int64(len(file.Data)),
+			})
+		}
+	}
+	return &metainfo.Metainfo{Info: info, Announce: announce}
+}
@@ -0,0 +1,50 @@
+package torrenttest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	a := Generate(1, 16, 10, 0, 40)
+	b := Generate(1, 16, 10, 0, 40)
+	c := Generate(2, 16, 10, 0, 40)
+	for i := range a.Files {
+		if !bytes.Equal(a.Files[i].Data, b.Files[i].Data) {
+			t.Errorf("file %d differs with equal seeds", i)
+		}
+	}
+	if bytes.Equal(a.Files[2].Data, c.Files[2].Data) {
+		t.Errorf("file 2 equal with different seeds")
+	}
+	if a.SingleFileMode() {
+		t.Errorf("multiple files in single-file mode")
+	}
+	if n := a.Length(); n != 50 {
+		t.Errorf("length %d", n)
+	}
+	if n := len(a.Pieces()) / 20; n != 4 {
+		t.Errorf("%d pieces (expected 4)", n)
+	}
+
+	single := Generate(1, 16, 32)
+	if !single.SingleFileMode() {
+		t.Errorf("single file not in single-file mode")
+	}
+	meta := single.Metainfo("http://example.com/announce")
+	if meta.Info.Length != 32 || len(meta.Info.Pieces) != 40 || meta.Info.MD5Sum == "" {
+		t.Errorf("unexpected single-file info %+v", meta.Info)
+	}
+
+	empty := Generate(1, 16, 0)
+	meta = empty.Metainfo("http://example.com/announce")
+	h := sha1.Sum(nil)
+	if !bytes.Equal(meta.Info.Pieces, h[:]) {
+		t.Errorf("empty content pieces %x (expected %x)", meta.Info.Pieces, h)
+	}
+	err := meta.Validate()
+	if err != nil {
+		t.Errorf("empty content: %v", err)
+	}
+}
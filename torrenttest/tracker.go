@@ -0,0 +1,240 @@
+package torrenttest
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bmatsuo/torrent/bencoding"
+	"github.com/bmatsuo/torrent/tracker"
+)
+
+// defaultNumWant is the number of peers returned to announces that do not
+// specify numwant.
+const defaultNumWant = 50
+
+var errInvalidID = errors.New("invalid info_hash or peer_id")
+
+// Tracker is an in-process HTTP tracker for tests.  It records announces and
+// returns preloaded peers along with the peers that have announced.
+type Tracker struct {
+	URL      string        // announce url
+	Interval time.Duration // announce interval returned to clients
+
+	srv       *httptest.Server
+	mut       sync.Mutex
+	swarms    map[string][]*swarmPeer
+	announces []tracker.AnnounceRequest
+}
+
+type swarmPeer struct {
+	tracker.Peer
+	seed bool
+}
+
+// NewTracker starts a Tracker.  Callers must call Close when finished.
+func NewTracker() *Tracker {
+	t := &Tracker{
+		Interval: 30 * time.Minute,
+		swarms:   make(map[string][]*swarmPeer),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/announce", t.announce)
+	mux.HandleFunc("/scrape", t.scrape)
+	t.srv = httptest.NewServer(mux)
+	t.URL = t.srv.URL + "/announce"
+	return t
+}
+
+// Close shuts down the tracker.
+func (t *Tracker) Close() {
+	t.srv.Close()
+}
+
+// AddPeer adds peer to the swarm of infoHash.
+func (t *Tracker) AddPeer(infoHash []byte, peer tracker.Peer, seed bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.addPeer(string(infoHash), &swarmPeer{peer, seed})
+}
+
+func (t *Tracker) addPeer(h string, peer *swarmPeer) {
+	// peers are identified by their id, or their address if they have none
+	for i, p := range t.swarms[h] {
+		same := p.String() == peer.String()
+		if peer.ID != nil {
+			same = bytes.Equal(p.ID, peer.ID)
+		}
+		if same {
+			t.swarms[h][i] = peer
+			return
+		}
+	}
+	t.swarms[h] = append(t.swarms[h], peer)
+}
+
+func (t *Tracker) removePeer(h string, id []byte) {
+	swarm := t.swarms[h]
+	for i, p := range swarm {
+		if bytes.Equal(p.ID, id) {
+			t.swarms[h] = append(swarm[:i], swarm[i+1:]...)
+			return
+		}
+	}
+}
+
+// Announces returns the announces received by t in order.
+func (t *Tracker) Announces() []tracker.AnnounceRequest {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return append([]tracker.AnnounceRequest(nil), t.announces...)
+}
+
+// Peers returns the peers in the swarm of infoHash.
+func (t *Tracker) Peers(infoHash []byte) []tracker.Peer {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	var peers []tracker.Peer
+	for _, p := range t.swarms[string(infoHash)] {
+		peers = append(peers, p.Peer)
+	}
+	return peers
+}
+
+func (t *Tracker) announce(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := tracker.AnnounceRequest{
+		InfoHash: []byte(q.Get("info_hash")),
+		PeerID:   []byte(q.Get("peer_id")),
+		Compact:  q.Get("compact") == "1",
+		NumWant:  -1,
+	}
+	var err error
+	req.Port, err = strconv.Atoi(q.Get("port"))
+	if err == nil {
+		req.Uploaded, _ = strconv.ParseInt(q.Get("uploaded"), 10, 64)
+		req.Downloaded, _ = strconv.ParseInt(q.Get("downloaded"), 10, 64)
+		req.Left, err = strconv.ParseInt(q.Get("left"), 10, 64)
+	}
+	if err == nil {
+		req.Event, err = tracker.ParseEvent(q.Get("event"))
+	}
+	if err == nil && q.Get("numwant") != "" {
+		req.NumWant, err = strconv.Atoi(q.Get("numwant"))
+	}
+	if err == nil && q.Get("key") != "" {
+		var key uint64
+		key, err = strconv.ParseUint(q.Get("key"), 16, 32)
+		req.Key = uint32(key)
+	}
+	if err == nil && (len(req.InfoHash) != 20 || len(req.PeerID) != 20) {
+		err = errInvalidID
+	}
+	if err != nil {
+		writeBencoded(w, map[string]interface{}{"failure reason": err.Error()})
+		return
+	}
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.announces = append(t.announces, req)
+	h := string(req.InfoHash)
+	if req.Event == tracker.Stopped {
+		t.removePeer(h, req.PeerID)
+	} else {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		t.addPeer(h, &swarmPeer{tracker.Peer{
+			ID:   req.PeerID,
+			IP:   net.ParseIP(host),
+			Port: req.Port,
+		}, req.Left == 0})
+	}
+
+	numwant := req.NumWant
+	if numwant < 0 {
+		numwant = defaultNumWant
+	}
+	var complete, incomplete int64
+	var compact, compact6 []byte
+	var dict []interface{}
+	for _, p := range t.swarms[h] {
+		if p.seed {
+			complete++
+		} else {
+			incomplete++
+		}
+		if bytes.Equal(p.ID, req.PeerID) || numwant == 0 {
+			continue
+		}
+		numwant--
+		switch ip4, ip6 := p.IP.To4(), p.IP.To16(); {
+		case req.Compact && ip4 != nil:
+			compact = append(compact, ip4...)
+			compact = append(compact, byte(p.Port>>8), byte(p.Port))
+		case req.Compact && ip6 != nil:
+			// IPv6 peers are returned under peers6 (BEP 7).
+			compact6 = append(compact6, ip6...)
+			compact6 = append(compact6, byte(p.Port>>8), byte(p.Port))
+		default:
+			dict = append(dict, map[string]interface{}{
+				"peer id": p.ID,
+				"ip":      p.IP.String(),
+				"port":    p.Port,
+			})
+		}
+	}
+	resp := map[string]interface{}{
+		"interval":   int64(t.Interval / time.Second),
+		"complete":   complete,
+		"incomplete": incomplete,
+	}
+	if req.Compact {
+		resp["peers"] = compact
+		if len(compact6) > 0 {
+			resp["peers6"] = compact6
+		}
+	} else {
+		resp["peers"] = dict
+	}
+	writeBencoded(w, resp)
+}
+
+func (t *Tracker) scrape(w http.ResponseWriter, r *http.Request) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	files := make(map[string]interface{})
+	for _, h := range r.URL.Query()["info_hash"] {
+		swarm, ok := t.swarms[h]
+		if !ok {
+			continue
+		}
+		var complete, incomplete int64
+		for _, p := range swarm {
+			if p.seed {
+				complete++
+			} else {
+				incomplete++
+			}
+		}
+		files[h] = map[string]interface{}{
+			"complete":   complete,
+			"downloaded": complete,
+			"incomplete": incomplete,
+		}
+	}
+	writeBencoded(w, map[string]interface{}{"files": files})
+}
+
+func writeBencoded(w http.ResponseWriter, v interface{}) {
+	p, err := bencoding.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(p)
+}
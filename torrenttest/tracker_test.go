@@ -0,0 +1,99 @@
+package torrenttest
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/bmatsuo/torrent/tracker"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	defer tr.Close()
+
+	hash := bytes.Repeat([]byte{1}, 20)
+	tr.AddPeer(hash, tracker.Peer{IP: net.IPv4(10, 0, 0, 1), Port: 6881}, true)
+
+	req := &tracker.AnnounceRequest{
+		InfoHash: hash,
+		PeerID:   []byte("-BT0000-aaaaaaaaaaaa"),
+		Port:     7000,
+		Left:     10,
+		Event:    tracker.Started,
+		NumWant:  -1,
+		Compact:  true,
+	}
+	resp, err := tracker.Announce(tr.URL, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Peers) != 1 || resp.Peers[0].String() != "10.0.0.1:6881" {
+		t.Errorf("peers %v", resp.Peers)
+	}
+	if resp.Complete != 1 || resp.Incomplete != 1 {
+		t.Errorf("complete %d incomplete %d", resp.Complete, resp.Incomplete)
+	}
+
+	req2 := *req
+	req2.PeerID = []byte("-BT0000-bbbbbbbbbbbb")
+	req2.Compact = false
+	resp, err = tracker.Announce(tr.URL, &req2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Peers) != 2 {
+		t.Errorf("peers %v", resp.Peers)
+	}
+
+	announces := tr.Announces()
+	if len(announces) != 2 || announces[0].Port != 7000 || announces[0].Event != tracker.Started {
+		t.Errorf("announces %+v", announces)
+	}
+
+	results, err := tracker.Scrape(tr.URL, [][]byte{hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0] == nil || results[0].Complete != 1 || results[0].Incomplete != 2 {
+		t.Errorf("scrape %+v", results[0])
+	}
+
+	req.Event = tracker.Stopped
+	_, err = tracker.Announce(tr.URL, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(tr.Peers(hash)); n != 2 {
+		t.Errorf("%d peers after stop (expected 2)", n)
+	}
+}
+
+func TestTracker_peers6(t *testing.T) {
+	tr := NewTracker()
+	defer tr.Close()
+
+	hash := bytes.Repeat([]byte{2}, 20)
+	tr.AddPeer(hash, tracker.Peer{IP: net.IPv4(10, 0, 0, 1), Port: 6881}, true)
+	tr.AddPeer(hash, tracker.Peer{IP: net.ParseIP("2001:db8::1"), Port: 6882}, true)
+
+	for _, compact := range []bool{true, false} {
+		resp, err := tracker.Announce(tr.URL, &tracker.AnnounceRequest{
+			InfoHash: hash,
+			PeerID:   []byte("-BT0000-aaaaaaaaaaaa"),
+			Port:     7000,
+			NumWant:  -1,
+			Compact:  compact,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var peers []string
+		for _, p := range resp.Peers {
+			peers = append(peers, p.String())
+		}
+		if len(peers) != 2 || peers[0] != "10.0.0.1:6881" || peers[1] != "[2001:db8::1]:6882" {
+			t.Errorf("compact %v: peers %v", compact, peers)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package torrenttest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+// Tree is content written to the file system with its torrent.
+type Tree struct {
+	Dir     string   // directory containing the content (but not the torrent)
+	Root    string   // path of the single file or top-level content directory
+	Paths   []string // path of each file, in torrent order
+	Torrent string   // path of the torrent file
+	Meta    *metainfo.Metainfo
+}
+
+// Materialize writes c and its torrent to a temporary directory which is
+// removed when the test completes.  Errors are reported with t.Fatal.
+func Materialize(t testing.TB, c *Content, announce string) *Tree {
+	tmp := t.TempDir()
+	tree := &Tree{
+		Dir:     filepath.Join(tmp, "data"),
+		Torrent: filepath.Join(tmp, c.Name+".torrent"),
+		Meta:    c.Metainfo(announce),
+	}
+	tree.Root = filepath.Join(tree.Dir, c.Name)
+	for _, file := range c.Files {
+		path := filepath.Join(append([]string{tree.Root}, file.Path...)...)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(path, file.Data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Paths = append(tree.Paths, path)
+	}
+	err := metainfo.WriteFile(tree.Torrent, tree.Meta, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
@@ -0,0 +1,38 @@
+package torrenttest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/torrent/metainfo"
+)
+
+func TestMaterialize(t *testing.T) {
+	for _, c := range []*Content{
+		Generate(1, 32, 100),
+		Generate(2, 32, 10, 0, 90),
+	} {
+		tree := Materialize(t, c, "http://example.com/announce")
+		if len(tree.Paths) != len(c.Files) {
+			t.Fatalf("%d paths for %d files", len(tree.Paths), len(c.Files))
+		}
+		for i, path := range tree.Paths {
+			p, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(p, c.Files[i].Data) {
+				t.Errorf("%s: unexpected content", path)
+			}
+		}
+		meta, err := metainfo.ReadFile(tree.Torrent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(meta, tree.Meta) {
+			t.Errorf("torrent %+v (expected %+v)", meta, tree.Meta)
+		}
+	}
+}
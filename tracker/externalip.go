@@ -0,0 +1,66 @@
+package tracker
+
+import (
+	"net"
+	"sync"
+)
+
+// ExternalIPs aggregates the external addresses reported by trackers (BEP 24)
+// into a best guess of the local host's public address, for use by
+// components such as a DHT node (BEP 42) or a listener.  The zero value is
+// ready to use and an ExternalIPs is safe for concurrent use.
+type ExternalIPs struct {
+	mut   sync.Mutex
+	votes map[string]int
+}
+
+// Add records an address reported by a tracker.  Nil addresses are ignored,
+// so the ExternalIP of every AnnounceResponse can be added.
+func (e *ExternalIPs) Add(ip net.IP) {
+	if ip == nil {
+		return
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if e.votes == nil {
+		e.votes = make(map[string]int)
+	}
+	e.votes[string(ip.To16())]++
+}
+
+// Best returns the address reported most often, or nil if no address has
+// been added.  IPv4 and IPv6 addresses are returned by Best4 and Best6.
+func (e *ExternalIPs) Best() net.IP {
+	return e.best(func(net.IP) bool { return true })
+}
+
+// Best4 returns the IPv4 address reported most often, or nil.
+func (e *ExternalIPs) Best4() net.IP {
+	return e.best(func(ip net.IP) bool { return ip.To4() != nil })
+}
+
+// Best6 returns the IPv6 address reported most often, or nil.
+func (e *ExternalIPs) Best6() net.IP {
+	return e.best(func(ip net.IP) bool { return ip.To4() == nil })
+}
+
+func (e *ExternalIPs) best(match func(net.IP) bool) net.IP {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	var best string
+	max := 0
+	for k, n := range e.votes {
+		// break ties deterministically.
+		if (n > max || n == max && k < best) && match(net.IP(k)) {
+			best, max = k, n
+		}
+	}
+	if max == 0 {
+		return nil
+	}
+	ip := net.IP(best)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return append(net.IP(nil), ip...)
+}
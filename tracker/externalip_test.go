@@ -0,0 +1,33 @@
+package tracker
+
+import (
+	"net"
+	"testing"
+)
+
+func TestExternalIPs(t *testing.T) {
+	var e ExternalIPs
+	if ip := e.Best(); ip != nil {
+		t.Errorf("best %v with no votes", ip)
+	}
+	for _, s := range []string{"192.0.2.1", "192.0.2.2", "2001:db8::1", "192.0.2.2", "2001:db8::1", "2001:db8::1"} {
+		e.Add(net.ParseIP(s))
+	}
+	e.Add(nil)
+	for _, test := range []struct {
+		name   string
+		ip     net.IP
+		expect string
+	}{
+		{"best", e.Best(), "2001:db8::1"},
+		{"best4", e.Best4(), "192.0.2.2"},
+		{"best6", e.Best6(), "2001:db8::1"},
+	} {
+		if test.ip.String() != test.expect {
+			t.Errorf("%s %v (expect %v)", test.name, test.ip, test.expect)
+		}
+	}
+	if len(e.Best4()) != net.IPv4len {
+		t.Errorf("best4 length %d", len(e.Best4()))
+	}
+}
@@ -0,0 +1,248 @@
+package tracker
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatsuo/torrent/bencoding"
+)
+
+// maxResponseSize limits the size of HTTP tracker responses.
+const maxResponseSize = 1 << 20
+
+// httpAnnounceResponse is the bencoded body of an HTTP announce response.
+// Every field is optional so failures and partial responses decode.
+type httpAnnounceResponse struct {
+	FailureReason  string               `bencoding:"failure reason,omitempty"`
+	RetryIn        interface{}          `bencoding:"retry in,omitempty"`
+	WarningMessage string               `bencoding:"warning message,omitempty"`
+	Interval       int64                `bencoding:"interval,omitempty"`
+	MinInterval    int64                `bencoding:"min interval,omitempty"`
+	TrackerID      string               `bencoding:"tracker id,omitempty"`
+	Complete       *int64               `bencoding:"complete,omitempty"`
+	Incomplete     *int64               `bencoding:"incomplete,omitempty"`
+	Peers          bencoding.RawMessage `bencoding:"peers,omitempty"`
+	Peers6         []byte               `bencoding:"peers6,omitempty"`
+	ExternalIP     []byte               `bencoding:"external ip,omitempty"`
+}
+
+// httpPeer is a peer in the dictionary peer format.
+type httpPeer struct {
+	ID   []byte `bencoding:"peer id,omitempty"`
+	IP   string `bencoding:"ip"`
+	Port int    `bencoding:"port"`
+}
+
+type httpScrapeResponse struct {
+	FailureReason string                      `bencoding:"failure reason,omitempty"`
+	RetryIn       interface{}                 `bencoding:"retry in,omitempty"`
+	Files         map[string]httpScrapeResult `bencoding:"files,omitempty"`
+}
+
+type httpScrapeResult struct {
+	Complete   int64 `bencoding:"complete,omitempty"`
+	Downloaded int64 `bencoding:"downloaded,omitempty"`
+	Incomplete int64 `bencoding:"incomplete,omitempty"`
+}
+
+func (c *Client) httpClient() *http.Client {
+	c.once.Do(func() {
+		c.transport = c.Transport
+		if c.transport == nil && c.TLSConfig != nil {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.TLSClientConfig = c.TLSConfig
+			c.transport = t
+		}
+	})
+	return &http.Client{Timeout: c.timeout(), Transport: c.transport}
+}
+
+// get requests u and returns the response body.
+func (c *Client) get(u *url.URL) ([]byte, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	p, err := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: maxResponseSize})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %s", resp.Status)
+	}
+	return p, nil
+}
+
+// addQuery appends the encoded query params to the query of u.  Existing
+// parameters (such as private tracker passkeys) are preserved.
+func addQuery(u *url.URL, params string) *url.URL {
+	v := *u
+	if v.RawQuery != "" {
+		v.RawQuery += "&"
+	}
+	v.RawQuery += params
+	return &v
+}
+
+func (c *Client) announceHTTP(u *url.URL, req *AnnounceRequest) (*AnnounceResponse, error) {
+	q := url.Values{}
+	q.Set("info_hash", string(req.InfoHash))
+	q.Set("peer_id", string(req.PeerID))
+	q.Set("port", strconv.Itoa(req.Port))
+	q.Set("uploaded", strconv.FormatInt(req.Uploaded, 10))
+	q.Set("downloaded", strconv.FormatInt(req.Downloaded, 10))
+	q.Set("left", strconv.FormatInt(req.Left, 10))
+	if req.Event != None {
+		q.Set("event", req.Event.String())
+	}
+	if req.NumWant >= 0 {
+		q.Set("numwant", strconv.Itoa(req.NumWant))
+	}
+	if req.Key != 0 {
+		q.Set("key", fmt.Sprintf("%08x", req.Key))
+	}
+	if req.Compact {
+		q.Set("compact", "1")
+	}
+	if req.IPv6 != nil {
+		q.Set("ipv6", req.IPv6.String())
+	}
+	p, err := c.get(addQuery(u, q.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	return parseHTTPAnnounce(p)
+}
+
+func parseHTTPAnnounce(p []byte) (*AnnounceResponse, error) {
+	var r httpAnnounceResponse
+	err := bencoding.Unmarshal(p, &r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid announce response: %v", err)
+	}
+	if r.FailureReason != "" {
+		return nil, failure(r.FailureReason, r.RetryIn)
+	}
+	resp := &AnnounceResponse{
+		Interval:    time.Duration(r.Interval) * time.Second,
+		MinInterval: time.Duration(r.MinInterval) * time.Second,
+		TrackerID:   r.TrackerID,
+		Complete:    -1,
+		Incomplete:  -1,
+		Warning:     r.WarningMessage,
+		Raw:         p,
+	}
+	if r.Complete != nil {
+		resp.Complete = *r.Complete
+	}
+	if r.Incomplete != nil {
+		resp.Incomplete = *r.Incomplete
+	}
+	if len(r.ExternalIP) == net.IPv4len || len(r.ExternalIP) == net.IPv6len {
+		resp.ExternalIP = net.IP(r.ExternalIP)
+	}
+	resp.Peers, err = parseHTTPPeers(r.Peers)
+	if err != nil {
+		return nil, err
+	}
+	peers6, err := parseCompactPeers6(r.Peers6)
+	if err != nil {
+		return nil, err
+	}
+	resp.Peers = append(resp.Peers, peers6...)
+	return resp, nil
+}
+
+// parseHTTPPeers parses peers in either the compact or dictionary format.
+func parseHTTPPeers(p []byte) ([]Peer, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	if p[0] != 'l' {
+		var compact []byte
+		err := bencoding.Unmarshal(p, &compact)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peers: %v", err)
+		}
+		return parseCompactPeers(compact)
+	}
+	var list []httpPeer
+	err := bencoding.Unmarshal(p, &list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peers: %v", err)
+	}
+	peers := make([]Peer, 0, len(list))
+	for _, hp := range list {
+		ip := net.ParseIP(hp.IP)
+		if ip == nil {
+			// some trackers return host names
+			ips, err := net.LookupIP(hp.IP)
+			if err != nil || len(ips) == 0 {
+				continue
+			}
+			ip = ips[0]
+		}
+		peers = append(peers, Peer{ID: hp.ID, IP: ip, Port: hp.Port})
+	}
+	return peers, nil
+}
+
+// scrapeURL returns the scrape url corresponding to an announce url,
+// following the convention that the last path element "announce" is
+// replaced by "scrape".
+func scrapeURL(u *url.URL) (*url.URL, error) {
+	dir, file := path.Split(u.Path)
+	if !strings.HasPrefix(file, "announce") {
+		return nil, fmt.Errorf("tracker does not support scrape")
+	}
+	v := *u
+	v.Path = dir + "scrape" + strings.TrimPrefix(file, "announce")
+	return &v, nil
+}
+
+func (c *Client) scrapeHTTP(u *url.URL, infoHashes [][]byte) ([]*ScrapeResult, error) {
+	u, err := scrapeURL(u)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	for _, h := range infoHashes {
+		q.Add("info_hash", string(h))
+	}
+	p, err := c.get(addQuery(u, q.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	var r httpScrapeResponse
+	err = bencoding.Unmarshal(p, &r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scrape response: %v", err)
+	}
+	if r.FailureReason != "" {
+		return nil, failure(r.FailureReason, r.RetryIn)
+	}
+	results := make([]*ScrapeResult, len(infoHashes))
+	for i, h := range infoHashes {
+		f, ok := r.Files[string(h)]
+		if ok {
+			results[i] = &ScrapeResult{f.Complete, f.Downloaded, f.Incomplete}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,203 @@
+package tracker
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var (
+	testInfoHash = bytes.Repeat([]byte{0xab}, 20)
+	testPeerID   = []byte("-BT0000-000000000000")
+)
+
+func TestAnnounceHTTP(t *testing.T) {
+	var query url.Values
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	req := &AnnounceRequest{
+		InfoHash: testInfoHash,
+		PeerID:   testPeerID,
+		Port:     6881,
+		Left:     100,
+		Event:    Started,
+		NumWant:  -1,
+		Compact:  true,
+		IPv6:     net.ParseIP("2001:db8::2"),
+	}
+	for _, test := range []struct {
+		body  string
+		peers []string
+		extip string
+		err   bool
+	}{
+		{"d8:intervali1800e5:peers6:\x7f\x00\x00\x01\x1a\xe1e", []string{"127.0.0.1:6881"}, "<nil>", false},
+		{"d8:intervali1800e5:peersld2:ip8:10.0.0.14:porti80eeee", []string{"10.0.0.1:80"}, "<nil>", false},
+		{"d11:external ip4:\xc0\x00\x02\x018:intervali1800e5:peers0:e", nil, "192.0.2.1", false},
+		{"d11:external ip16:\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x018:intervali1800e5:peers0:e", nil, "2001:db8::1", false},
+		{"d11:external ip3:xyz8:intervali1800e5:peers0:e", nil, "<nil>", false},
+		{"d8:intervali1800e5:peers6:\x7f\x00\x00\x01\x1a\xe16:peers618:\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x1a\xe2e", []string{"127.0.0.1:6881", "[::1]:6882"}, "<nil>", false},
+		{"d14:failure reason6:bannede", nil, "", true},
+		{"d8:intervali1800e5:peers0:6:peers63:xyze", nil, "", true},
+		{"d8:intervali1800e5:peers5:xxxxxe", nil, "", true},
+		{"garbage", nil, "", true},
+	} {
+		body = test.body
+		resp, err := Announce(srv.URL+"/announce?passkey=x", req)
+		if test.err {
+			if err == nil {
+				t.Errorf("announce %q: expected error", test.body)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("announce %q: %v", test.body, err)
+			continue
+		}
+		if len(resp.Peers) != len(test.peers) {
+			t.Errorf("announce %q: got %d peers (expect %d)", test.body, len(resp.Peers), len(test.peers))
+			continue
+		}
+		for i := range resp.Peers {
+			if resp.Peers[i].String() != test.peers[i] {
+				t.Errorf("announce %q: peer %d is %v (expect %v)", test.body, i, resp.Peers[i], test.peers[i])
+			}
+		}
+		if resp.ExternalIP.String() != test.extip {
+			t.Errorf("announce %q: external ip %v (expect %v)", test.body, resp.ExternalIP, test.extip)
+		}
+		if string(resp.Raw) != test.body {
+			t.Errorf("announce %q: raw response %q", test.body, resp.Raw)
+		}
+		if resp.Interval.Seconds() != 1800 {
+			t.Errorf("announce %q: interval %v", test.body, resp.Interval)
+		}
+	}
+
+	if query.Get("info_hash") != string(testInfoHash) {
+		t.Errorf("info_hash %q", query.Get("info_hash"))
+	}
+	for key, expect := range map[string]string{
+		"passkey": "x",
+		"port":    "6881",
+		"left":    "100",
+		"event":   "started",
+		"compact": "1",
+		"ipv6":    "2001:db8::2",
+		"numwant": "",
+	} {
+		if query.Get(key) != expect {
+			t.Errorf("query %s=%q (expect %q)", key, query.Get(key), expect)
+		}
+	}
+}
+
+func TestParseHTTPAnnounce_retryIn(t *testing.T) {
+	for _, test := range []struct {
+		body      string
+		retryIn   time.Duration
+		permanent bool
+	}{
+		{"d14:failure reason4:busye", 0, false},
+		{"d14:failure reason4:busy8:retry ini30ee", 30 * time.Minute, false},
+		{"d14:failure reason4:busy8:retry ini-1ee", 0, false},
+		{"d14:failure reason6:banned8:retry in5:nevere", 0, true},
+	} {
+		_, err := parseHTTPAnnounce([]byte(test.body))
+		terr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("announce %q: error %v", test.body, err)
+			continue
+		}
+		if terr.RetryIn != test.retryIn || terr.Permanent != test.permanent {
+			t.Errorf("announce %q: retry in %v permanent %t", test.body, terr.RetryIn, terr.Permanent)
+		}
+	}
+}
+
+func TestClient_http(t *testing.T) {
+	var agent string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+		w.Write([]byte("d8:intervali1800e5:peers0:e"))
+	}))
+	defer srv.Close()
+	req := &AnnounceRequest{InfoHash: testInfoHash, PeerID: testPeerID, Port: 6881, NumWant: -1}
+
+	_, err := Announce(srv.URL+"/announce", req)
+	if err == nil {
+		t.Errorf("announced to a tracker with an unknown certificate")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	c := &Client{UserAgent: "test/1.0", TLSConfig: &tls.Config{RootCAs: roots}}
+	_, err = c.Announce(srv.URL+"/announce", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent != "test/1.0" {
+		t.Errorf("user agent %q", agent)
+	}
+
+	rt := &countingTransport{RoundTripper: srv.Client().Transport}
+	c = &Client{Transport: rt}
+	_, err = c.Announce(srv.URL+"/announce", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.n != 1 {
+		t.Errorf("transport made %d requests", rt.n)
+	}
+}
+
+type countingTransport struct {
+	http.RoundTripper
+	n int
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.n++
+	return t.RoundTripper.RoundTrip(r)
+}
+
+func TestScrapeHTTP(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte("d5:filesd20:" + string(testInfoHash) +
+			"d8:completei5e10:downloadedi7e10:incompletei3eeee"))
+	}))
+	defer srv.Close()
+
+	other := bytes.Repeat([]byte{1}, 20)
+	results, err := Scrape(srv.URL+"/x/announce.php", [][]byte{testInfoHash, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/x/scrape.php" {
+		t.Errorf("scrape path %q", path)
+	}
+	if len(results) != 2 || results[1] != nil {
+		t.Fatalf("results %v", results)
+	}
+	if *results[0] != (ScrapeResult{5, 7, 3}) {
+		t.Errorf("result %+v", *results[0])
+	}
+
+	_, err = Scrape(srv.URL+"/tracker", [][]byte{testInfoHash})
+	if err == nil {
+		t.Errorf("expected error scraping without an announce path")
+	}
+}
@@ -0,0 +1,263 @@
+/*
+Package tracker implements the client side of the BitTorrent tracker
+protocols over HTTP (BEP 3, BEP 23, BEP 48) and UDP (BEP 15).
+
+This package API is unstable and may change without notice.
+*/
+package tracker
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the time limit for tracker requests made by a Client
+// with no Timeout.
+const DefaultTimeout = 15 * time.Second
+
+// Event is the event reported by an announce.  The values of events match
+// their encoding in the UDP tracker protocol.
+type Event int32
+
+const (
+	None Event = iota
+	Completed
+	Started
+	Stopped
+)
+
+var eventNames = []string{"", "completed", "started", "stopped"}
+
+// String returns the name of e used by HTTP trackers.  None is the empty
+// string.
+func (e Event) String() string {
+	if e < 0 || int(e) >= len(eventNames) {
+		return "Event(" + strconv.Itoa(int(e)) + ")"
+	}
+	return eventNames[e]
+}
+
+// ParseEvent returns the Event named s.
+func ParseEvent(s string) (Event, error) {
+	for i, name := range eventNames {
+		if s == name {
+			return Event(i), nil
+		}
+	}
+	if s == "none" {
+		return None, nil
+	}
+	return None, fmt.Errorf("unknown event %q", s)
+}
+
+// AnnounceRequest contains the parameters of an announce.
+type AnnounceRequest struct {
+	InfoHash   []byte // 20 byte info hash
+	PeerID     []byte // 20 byte peer id
+	Port       int
+	Uploaded   int64
+	Downloaded int64
+	Left       int64
+	Event      Event
+	NumWant    int // negative values request the tracker default
+	Key        uint32
+	Compact    bool // request compact peers (HTTP only; UDP is always compact)
+
+	// IPv6 is the client's IPv6 address, which an HTTP tracker reached
+	// over IPv4 can give to IPv6 peers (BEP 7).
+	IPv6 net.IP
+}
+
+// Peer is a peer returned by an announce.  ID is nil for compact peers.
+type Peer struct {
+	ID   []byte
+	IP   net.IP
+	Port int
+}
+
+func (p Peer) String() string {
+	return net.JoinHostPort(p.IP.String(), strconv.Itoa(p.Port))
+}
+
+// AnnounceResponse is a tracker's response to a successful announce.
+// Complete and Incomplete are -1 if the tracker did not report them.  Peers
+// includes the IPv6 peers of an HTTP response's "peers6" key.
+type AnnounceResponse struct {
+	Interval    time.Duration
+	MinInterval time.Duration
+	TrackerID   string
+	Complete    int64
+	Incomplete  int64
+	Peers       []Peer
+	Warning     string
+	ExternalIP  net.IP // the requester's address as seen by the tracker (BEP 24)
+	Raw         []byte // the bencoded response of an HTTP tracker
+}
+
+// ScrapeResult is the swarm information for one torrent from a scrape.
+type ScrapeResult struct {
+	Complete   int64 // seeders
+	Downloaded int64 // completed downloads
+	Incomplete int64 // leechers
+}
+
+// Error is a failure reported by a tracker.
+type Error struct {
+	Reason string
+
+	// RetryIn is the time the tracker asked clients to wait before
+	// retrying the request, or zero if the tracker did not say (BEP 31).
+	RetryIn time.Duration
+
+	// Permanent is true if the tracker asked clients never to retry the
+	// request, in which case the tracker should be removed from rotation.
+	Permanent bool
+}
+
+func (err *Error) Error() string {
+	return "tracker failure: " + err.Reason
+}
+
+// failure returns the Error for a failure reason and a "retry in" value,
+// which is a number of minutes or the string "never".
+func failure(reason string, retryIn interface{}) *Error {
+	err := &Error{Reason: reason}
+	switch x := retryIn.(type) {
+	case int64:
+		if x > 0 {
+			err.RetryIn = time.Duration(x) * time.Minute
+		}
+	case string:
+		err.Permanent = x == "never"
+	}
+	return err
+}
+
+// Client makes requests to trackers.  The zero value is ready to use.  A
+// Client must not be copied after its first HTTP request.
+type Client struct {
+	// Timeout limits the duration of each request.  If zero DefaultTimeout
+	// is used.
+	Timeout time.Duration
+
+	// Network is the network used for UDP trackers, "udp4" or "udp6".  If
+	// empty the address family follows the tracker's address.
+	Network string
+
+	// LocalUDPAddr, if not nil, is the local address UDP requests are sent
+	// from.
+	LocalUDPAddr *net.UDPAddr
+
+	// UserAgent is sent as the User-Agent header of HTTP requests, which
+	// some private trackers use to allow only known clients.  If empty
+	// the Go default is sent.
+	UserAgent string
+
+	// TLSConfig configures HTTPS requests, for example to use custom root
+	// certificates.  It is ignored if Transport is not nil.
+	TLSConfig *tls.Config
+
+	// Transport performs HTTP requests, and can be used to supply a custom
+	// dialer or proxy.  If nil http.DefaultTransport is used, or a copy of
+	// it using TLSConfig.
+	Transport http.RoundTripper
+
+	once      sync.Once
+	transport http.RoundTripper
+}
+
+var defaultClient = new(Client)
+
+// Announce performs an announce using a zero Client.
+func Announce(tracker string, req *AnnounceRequest) (*AnnounceResponse, error) {
+	return defaultClient.Announce(tracker, req)
+}
+
+// Scrape performs a scrape using a zero Client.
+func Scrape(tracker string, infoHashes [][]byte) ([]*ScrapeResult, error) {
+	return defaultClient.Scrape(tracker, infoHashes)
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultTimeout
+}
+
+// Announce announces req to the tracker with the given announce url.
+func (c *Client) Announce(tracker string, req *AnnounceRequest) (*AnnounceResponse, error) {
+	if len(req.InfoHash) != 20 {
+		return nil, fmt.Errorf("invalid info hash length %d", len(req.InfoHash))
+	}
+	if len(req.PeerID) != 20 {
+		return nil, fmt.Errorf("invalid peer id length %d", len(req.PeerID))
+	}
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return c.announceHTTP(u, req)
+	case "udp":
+		return c.announceUDP(u, req)
+	}
+	return nil, fmt.Errorf("unsupported tracker scheme %q", u.Scheme)
+}
+
+// Scrape requests swarm information for infoHashes from the tracker with the
+// given announce url.  The returned slice has an element for each info hash,
+// which is nil if the tracker did not report on the torrent.
+func (c *Client) Scrape(tracker string, infoHashes [][]byte) ([]*ScrapeResult, error) {
+	for _, h := range infoHashes {
+		if len(h) != 20 {
+			return nil, fmt.Errorf("invalid info hash length %d", len(h))
+		}
+	}
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return c.scrapeHTTP(u, infoHashes)
+	case "udp":
+		return c.scrapeUDP(u, infoHashes)
+	}
+	return nil, fmt.Errorf("unsupported tracker scheme %q", u.Scheme)
+}
+
+// parseCompactPeers parses peers in the compact format of BEP 23.
+func parseCompactPeers(p []byte) ([]Peer, error) {
+	return parseCompact(p, net.IPv4len)
+}
+
+// parseCompactPeers6 parses IPv6 peers in the compact format of BEP 7.
+func parseCompactPeers6(p []byte) ([]Peer, error) {
+	return parseCompact(p, net.IPv6len)
+}
+
+// parseCompact parses compact peers having addresses of length iplen.
+func parseCompact(p []byte, iplen int) ([]Peer, error) {
+	size := iplen + 2
+	if len(p)%size != 0 {
+		return nil, fmt.Errorf("invalid compact peers length %d", len(p))
+	}
+	peers := make([]Peer, 0, len(p)/size)
+	for i := 0; i < len(p); i += size {
+		ip := make(net.IP, iplen)
+		copy(ip, p[i:i+iplen])
+		peers = append(peers, Peer{
+			IP:   ip,
+			Port: int(p[i+iplen])<<8 | int(p[i+iplen+1]),
+		})
+	}
+	return peers, nil
+}
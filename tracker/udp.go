@@ -0,0 +1,193 @@
+package tracker
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// udpProtocolID is the magic connection id of a BEP 15 connect request.
+const udpProtocolID = 0x41727101980
+
+// Actions of the UDP tracker protocol.
+const (
+	udpConnect int32 = iota
+	udpAnnounce
+	udpScrape
+	udpError
+)
+
+// maxUDPPayload is the largest UDP payload, and so the largest response a
+// tracker can send.
+const maxUDPPayload = 65507
+
+// maxUDPScrape is the maximum number of info hashes in a UDP scrape request.
+const maxUDPScrape = 74
+
+// udpConn is a connection to a UDP tracker.
+type udpConn struct {
+	conn    net.Conn
+	timeout time.Duration
+	id      int64 // connection id
+}
+
+func (c *Client) dialUDP(u *url.URL) (*udpConn, error) {
+	network := c.Network
+	if network == "" {
+		network = "udp"
+	}
+	dialer := &net.Dialer{Timeout: c.timeout()}
+	if c.LocalUDPAddr != nil {
+		dialer.LocalAddr = c.LocalUDPAddr
+	}
+	conn, err := dialer.Dial(network, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	uc := &udpConn{conn: conn, timeout: c.timeout()}
+	var resp []byte
+	resp, err = uc.request(udpConnect, nil)
+	if err == nil && len(resp) < 8 {
+		err = fmt.Errorf("short connect response")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	uc.id = int64(binary.BigEndian.Uint64(resp))
+	return uc, nil
+}
+
+// ipv6 returns true if the tracker is reached over IPv6, in which case
+// announce responses contain IPv6 peers.
+func (uc *udpConn) ipv6() bool {
+	addr, ok := uc.conn.RemoteAddr().(*net.UDPAddr)
+	return ok && addr.IP.To4() == nil
+}
+
+func (uc *udpConn) Close() error {
+	return uc.conn.Close()
+}
+
+// request sends a request with the given action and body and returns the
+// body of the tracker's response.
+func (uc *udpConn) request(action int32, body []byte) ([]byte, error) {
+	var txid int32
+	err := binary.Read(rand.Reader, binary.BigEndian, &txid)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if action == udpConnect {
+		binary.Write(&buf, binary.BigEndian, int64(udpProtocolID))
+	} else {
+		binary.Write(&buf, binary.BigEndian, uc.id)
+	}
+	binary.Write(&buf, binary.BigEndian, action)
+	binary.Write(&buf, binary.BigEndian, txid)
+	buf.Write(body)
+
+	uc.conn.SetDeadline(time.Now().Add(uc.timeout))
+	_, err = uc.conn.Write(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	p := make([]byte, maxUDPPayload)
+	for {
+		n, err := uc.conn.Read(p)
+		if err != nil {
+			return nil, err
+		}
+		if n < 8 || int32(binary.BigEndian.Uint32(p[4:])) != txid {
+			// a stray or late datagram
+			continue
+		}
+		respAction := int32(binary.BigEndian.Uint32(p))
+		if respAction == udpError {
+			return nil, &Error{Reason: string(p[8:n])}
+		}
+		if respAction != action {
+			return nil, fmt.Errorf("unexpected action %d in response", respAction)
+		}
+		return p[8:n], nil
+	}
+}
+
+func (c *Client) announceUDP(u *url.URL, req *AnnounceRequest) (*AnnounceResponse, error) {
+	uc, err := c.dialUDP(u)
+	if err != nil {
+		return nil, err
+	}
+	defer uc.Close()
+
+	var buf bytes.Buffer
+	buf.Write(req.InfoHash)
+	buf.Write(req.PeerID)
+	binary.Write(&buf, binary.BigEndian, req.Downloaded)
+	binary.Write(&buf, binary.BigEndian, req.Left)
+	binary.Write(&buf, binary.BigEndian, req.Uploaded)
+	binary.Write(&buf, binary.BigEndian, int32(req.Event))
+	binary.Write(&buf, binary.BigEndian, uint32(0)) // default ip
+	binary.Write(&buf, binary.BigEndian, req.Key)
+	numwant := int32(req.NumWant)
+	if numwant < 0 {
+		numwant = -1
+	}
+	binary.Write(&buf, binary.BigEndian, numwant)
+	binary.Write(&buf, binary.BigEndian, uint16(req.Port))
+
+	p, err := uc.request(udpAnnounce, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(p) < 12 {
+		return nil, fmt.Errorf("short announce response")
+	}
+	resp := &AnnounceResponse{
+		Interval:   time.Duration(binary.BigEndian.Uint32(p)) * time.Second,
+		Incomplete: int64(binary.BigEndian.Uint32(p[4:])),
+		Complete:   int64(binary.BigEndian.Uint32(p[8:])),
+	}
+	if uc.ipv6() {
+		resp.Peers, err = parseCompactPeers6(p[12:])
+	} else {
+		resp.Peers, err = parseCompactPeers(p[12:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) scrapeUDP(u *url.URL, infoHashes [][]byte) ([]*ScrapeResult, error) {
+	if len(infoHashes) > maxUDPScrape {
+		return nil, fmt.Errorf("too many info hashes for udp scrape (max %d)", maxUDPScrape)
+	}
+	uc, err := c.dialUDP(u)
+	if err != nil {
+		return nil, err
+	}
+	defer uc.Close()
+
+	p, err := uc.request(udpScrape, bytes.Join(infoHashes, nil))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*ScrapeResult, len(infoHashes))
+	for i := range results {
+		if len(p) < 12 {
+			break
+		}
+		results[i] = &ScrapeResult{
+			Complete:   int64(binary.BigEndian.Uint32(p)),
+			Downloaded: int64(binary.BigEndian.Uint32(p[4:])),
+			Incomplete: int64(binary.BigEndian.Uint32(p[8:])),
+		}
+		p = p[12:]
+	}
+	return results, nil
+}
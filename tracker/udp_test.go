@@ -0,0 +1,159 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// udpTestServer responds to UDP tracker requests with fixed responses.
+func udpTestServer(t *testing.T, failAnnounce bool) (net.PacketConn, string) {
+	return udpTestServerAddr(t, "127.0.0.1:0", 1, failAnnounce)
+}
+
+// udpTestServerAddr is like udpTestServer but listens on addr and responds
+// to announces with npeers peers.  The peers are IPv6 if addr is an IPv6
+// address.
+func udpTestServerAddr(t *testing.T, addr string, npeers int, failAnnounce bool) (net.PacketConn, string) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", addr, err)
+	}
+	ipv6 := conn.LocalAddr().(*net.UDPAddr).IP.To4() == nil
+	go func() {
+		p := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(p)
+			if err != nil {
+				return
+			}
+			if n < 16 {
+				continue
+			}
+			action := int32(binary.BigEndian.Uint32(p[8:]))
+			var buf bytes.Buffer
+			if action == udpAnnounce && failAnnounce {
+				action = udpError
+			}
+			binary.Write(&buf, binary.BigEndian, action)
+			buf.Write(p[12:16]) // transaction id
+			switch action {
+			case udpConnect:
+				binary.Write(&buf, binary.BigEndian, int64(42))
+			case udpAnnounce:
+				binary.Write(&buf, binary.BigEndian, []uint32{900, 2, 1})
+				for i := 2; i < npeers+2; i++ {
+					if ipv6 {
+						ip := net.ParseIP("2001:db8::")
+						ip[14], ip[15] = byte(i>>8), byte(i)
+						buf.Write(ip)
+					} else {
+						buf.Write([]byte{10, 0, byte(i >> 8), byte(i)})
+					}
+					buf.Write([]byte{0x1a, 0xe1})
+				}
+			case udpScrape:
+				for i := 16; i+20 <= n; i += 20 {
+					binary.Write(&buf, binary.BigEndian, []uint32{1, 2, 3})
+				}
+			case udpError:
+				buf.WriteString("unregistered torrent")
+			}
+			conn.WriteTo(buf.Bytes(), addr)
+		}
+	}()
+	return conn, "udp://" + conn.LocalAddr().String() + "/announce"
+}
+
+func TestAnnounceUDP(t *testing.T) {
+	conn, u := udpTestServer(t, false)
+	defer conn.Close()
+
+	resp, err := Announce(u, &AnnounceRequest{
+		InfoHash: testInfoHash,
+		PeerID:   testPeerID,
+		Port:     6881,
+		NumWant:  -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Interval.Seconds() != 900 || resp.Incomplete != 2 || resp.Complete != 1 {
+		t.Errorf("response %+v", resp)
+	}
+	if len(resp.Peers) != 1 || resp.Peers[0].String() != "10.0.0.2:6881" {
+		t.Errorf("peers %v", resp.Peers)
+	}
+}
+
+func TestAnnounceUDP_ipv6(t *testing.T) {
+	conn, u := udpTestServerAddr(t, "[::1]:0", 1, false)
+	defer conn.Close()
+
+	c := &Client{Network: "udp6"}
+	resp, err := c.Announce(u, &AnnounceRequest{
+		InfoHash: testInfoHash,
+		PeerID:   testPeerID,
+		Port:     6881,
+		NumWant:  -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Peers) != 1 || resp.Peers[0].String() != "[2001:db8::2]:6881" {
+		t.Errorf("peers %v", resp.Peers)
+	}
+
+	c = &Client{Network: "udp4"}
+	_, err = c.Announce(u, &AnnounceRequest{InfoHash: testInfoHash, PeerID: testPeerID})
+	if err == nil {
+		t.Errorf("announce to an IPv6 tracker over udp4 succeeded")
+	}
+}
+
+func TestAnnounceUDP_manyIPv6(t *testing.T) {
+	const npeers = 200 // more than fit in a 2048 byte response
+	conn, u := udpTestServerAddr(t, "[::1]:0", npeers, false)
+	defer conn.Close()
+
+	c := &Client{Network: "udp6"}
+	resp, err := c.Announce(u, &AnnounceRequest{
+		InfoHash: testInfoHash,
+		PeerID:   testPeerID,
+		Port:     6881,
+		NumWant:  -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Peers) != npeers {
+		t.Fatalf("%d peers (expected %d)", len(resp.Peers), npeers)
+	}
+	if last := resp.Peers[npeers-1].String(); last != "[2001:db8::c9]:6881" {
+		t.Errorf("last peer %v", last)
+	}
+}
+
+func TestAnnounceUDP_failure(t *testing.T) {
+	conn, u := udpTestServer(t, true)
+	defer conn.Close()
+
+	_, err := Announce(u, &AnnounceRequest{InfoHash: testInfoHash, PeerID: testPeerID})
+	if err, ok := err.(*Error); !ok || err.Reason != "unregistered torrent" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestScrapeUDP(t *testing.T) {
+	conn, u := udpTestServer(t, false)
+	defer conn.Close()
+
+	results, err := Scrape(u, [][]byte{testInfoHash, testInfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || *results[1] != (ScrapeResult{1, 2, 3}) {
+		t.Errorf("results %v", results)
+	}
+}